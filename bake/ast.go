@@ -16,33 +16,47 @@ import (
 	"go/printer"
 	"go/token"
 	"iter"
+	"path"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
 )
 
 type ParsedFile struct {
-	file string
-	fset *token.FileSet
-	f    *ast.File
+	file    string
+	fset    *token.FileSet
+	f       *ast.File
+	pkgName string
+	pkgPath string
 }
 
+// parsedFiles - parses the files of the packages matching the given patterns.
+// The patterns are resolved from dir, so imported packages are read from the module cache.
+// Defaults to the package in dir.
+//
 // Requires GOEXPERIMENT=rangefunc
-func parsedFiles(dir string) iter.Seq2[ParsedFile, error] {
+func parsedFiles(dir string, patterns ...string) iter.Seq2[ParsedFile, error] {
 	return func(yield func(ParsedFile, error) bool) {
-		cfg := &packages.Config{Mode: packages.NeedFiles | packages.NeedSyntax, Dir: dir}
-		pkgs, err := packages.Load(cfg, ".")
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		cfg := &packages.Config{Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax, Dir: dir}
+		pkgs, err := packages.Load(cfg, patterns...)
 		if err != nil {
 			yield(ParsedFile{}, fmt.Errorf("failed to load packages: %w", err))
 			return
 		}
 		for _, pkg := range pkgs {
 			// Logger.Println(pkg.ID, pkg.GoFiles)
+			if len(pkg.GoFiles) == 0 && len(pkg.Errors) > 0 {
+				yield(ParsedFile{}, fmt.Errorf("failed to load package '%s': %w", pkg.ID, pkg.Errors[0]))
+				return
+			}
 			for _, file := range pkg.GoFiles {
 				if strings.Contains(file, "generated") {
 					continue
 				}
-				p := ParsedFile{}
+				p := ParsedFile{pkgName: pkg.Name, pkgPath: pkg.PkgPath}
 				// Logger.Printf("file: %s\n", file)
 				// parse file
 				fset := token.NewFileSet()
@@ -63,6 +77,43 @@ func parsedFiles(dir string) iter.Seq2[ParsedFile, error] {
 	}
 }
 
+// declaredNames - Returns the names the file declares at file and package level.
+// The import names are file level so they only matter for the file itself.
+func declaredNames(f *ast.File, withImports bool) []string {
+	names := []string{}
+	if withImports {
+		for _, imp := range f.Imports {
+			if imp.Name != nil {
+				names = append(names, imp.Name.Name)
+				continue
+			}
+			// The package name is the last path element, go-getoptions is getoptions
+			p := strings.Trim(imp.Path.Value, `"`)
+			names = append(names, strings.TrimPrefix(path.Base(p), "go-"))
+		}
+	}
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				names = append(names, decl.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, spec.Name.Name)
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
 type FnDecl struct {
 	Name        string // function name
 	Description string
@@ -73,9 +124,9 @@ type FnDecl struct {
 }
 
 // Requires GOEXPERIMENT=rangefunc
-func AstFns(dir string) iter.Seq2[FnDecl, error] {
+func AstFns(dir string, patterns ...string) iter.Seq2[FnDecl, error] {
 	return func(yield func(FnDecl, error) bool) {
-		for p, err := range parsedFiles(dir) {
+		for p, err := range parsedFiles(dir, patterns...) {
 			if err != nil {
 				yield(FnDecl{}, err)
 				return
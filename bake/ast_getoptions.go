@@ -11,11 +11,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"go/ast"
 	"go/printer"
 	"iter"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/DavidGamba/go-getoptions"
 )
@@ -31,29 +34,158 @@ import (
 func LoadAst(ctx context.Context, opt *getoptions.GetOpt, dir string) (*OptTree, error) {
 	ot := NewOptTree(opt)
 
+	local := make(map[string]struct{})
 	for getOptFn, err := range AstGetoptionFns(ctx, dir) {
 		if err != nil {
 			return ot, err
 		}
+		local[getOptFn.DescName] = struct{}{}
 
-		cmd, err := ot.AddCommand(getOptFn.Name, getOptFn.DescName, getOptFn.Description)
+		err = addGetOptFn(ot, getOptFn)
 		if err != nil {
 			return ot, err
 		}
-		err = addOptionsToCMD(getOptFn, cmd, getOptFn.DescName)
+	}
+
+	envFiles, err := bakeEnvFiles(dir)
+	if err != nil {
+		return ot, err
+	}
+	ot.EnvFiles = envFiles
+
+	imports, err := bakeImports(dir)
+	if err != nil {
+		return ot, err
+	}
+	if len(imports) > 0 {
+		err = reserveNames(ot, dir)
 		if err != nil {
 			return ot, err
 		}
 	}
+	importDirs := make(map[string]struct{})
+	for _, importPath := range imports {
+		Logger.Printf("Loading tasks from %s\n", importPath)
+		for getOptFn, err := range AstGetoptionFns(ctx, dir, importPath) {
+			if err != nil {
+				return ot, fmt.Errorf("failed to load tasks from '%s': %w", importPath, err)
+			}
+			// Local tasks override imported tasks
+			if _, ok := local[getOptFn.DescName]; ok {
+				Logger.Printf("Task %s from %s overridden by local task\n", getOptFn.DescName, importPath)
+				continue
+			}
+			// Track the package dir to rebuild when it changes
+			d := filepath.Dir(getOptFn.ParsedFile.file)
+			if _, ok := importDirs[d]; !ok {
+				importDirs[d] = struct{}{}
+				ot.ImportDirs = append(ot.ImportDirs, d)
+			}
+			alias := ot.AddImport(getOptFn.ParsedFile.pkgPath, getOptFn.ParsedFile.pkgName)
+			getOptFn.Name = alias + "." + getOptFn.Name
+
+			err = addGetOptFn(ot, getOptFn)
+			if err != nil {
+				return ot, err
+			}
+		}
+	}
 
 	return ot, nil
 }
 
+// reserveNames - Reserves the names declared by the generated main file and the package level names of the bakefiles.
+// The import aliases share the package block with them.
+func reserveNames(ot *OptTree, dir string) error {
+	names, err := templateNames()
+	if err != nil {
+		return err
+	}
+	ot.ReserveNames(names...)
+	for p, err := range parsedFiles(dir) {
+		if err != nil {
+			return err
+		}
+		ot.ReserveNames(declaredNames(p.f, false)...)
+	}
+	return nil
+}
+
+func addGetOptFn(ot *OptTree, getOptFn GetOptFn) error {
+	cmd, err := ot.AddCommand(getOptFn.Name, getOptFn.DescName, getOptFn.Description, getOptFn.Directives)
+	if err != nil {
+		// Keep loading like before duplicates were detected, the first declaration wins
+		if errors.Is(err, ErrDuplicateCommand) {
+			Logger.Printf("Ignoring %s, run 'bake _bake lint' for details\n", err)
+			return nil
+		}
+		return err
+	}
+	options, err := addOptionsToCMD(getOptFn, cmd, getOptFn.DescName)
+	if err != nil {
+		return err
+	}
+	if node := ot.Node(getOptFn.DescName); node != nil {
+		node.Options = options
+	}
+	return nil
+}
+
 type GetOptFn struct {
 	FnDecl
 
 	DescName     string
 	OptFieldName string
+	Directives   Directives
+}
+
+// Directives - bake directives declared in the function doc comment.
+// Directive comments are not part of the task description.
+//
+//	// build:binary - Builds the binary
+//	//
+//	//bake:timeout 10m
+//	//bake:depends build:diagram
+//	//bake:env build.env
+//	func Binary(opt *getoptions.GetOpt) getoptions.CommandFn {
+type Directives struct {
+	Timeout string   `json:"timeout,omitempty"` // default task timeout, a time.ParseDuration string
+	Depends []string `json:"depends,omitempty"` // task IDs bake run runs before the task, ordering metadata for bake run only
+	Env     []string `json:"env,omitempty"`     // env files loaded before running the task
+}
+
+const directivePrefix = "//bake:"
+
+func parseDirectives(fnDecl FnDecl) (Directives, error) {
+	d := Directives{}
+	x := fnDecl.Node.(*ast.FuncDecl)
+	if x.Doc == nil {
+		return d, nil
+	}
+	for _, c := range x.Doc.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(c.Text, directivePrefix), " ")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "timeout":
+			if _, err := time.ParseDuration(value); err != nil {
+				return d, fmt.Errorf("%s: invalid bake:timeout directive: %w", fnDecl.Name, err)
+			}
+			d.Timeout = value
+		case "depends":
+			d.Depends = append(d.Depends, strings.Fields(value)...)
+		case "env":
+			if value == "" {
+				return d, fmt.Errorf("%s: bake:env directive requires at least one file", fnDecl.Name)
+			}
+			d.Env = append(d.Env, strings.Fields(value)...)
+		default:
+			return d, fmt.Errorf("%s: unknown directive '%s'", fnDecl.Name, strings.TrimPrefix(c.Text, "//"))
+		}
+	}
+	return d, nil
 }
 
 // The goal is to be able to find the getoptions.CommandFn calls.
@@ -64,72 +196,97 @@ type GetOptFn struct {
 //		opt.String("hello", "world")
 //		opt.String("hola", "mundo")
 //		return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
-func AstGetoptionFns(ctx context.Context, dir string) iter.Seq2[GetOptFn, error] {
+func AstGetoptionFns(ctx context.Context, dir string, patterns ...string) iter.Seq2[GetOptFn, error] {
 	return func(yield func(GetOptFn, error) bool) {
-		// Regex for description: fn-name - description
-		re := regexp.MustCompile(`^\w\S+ -`)
-
-	LOOP:
-		for fnDecl, err := range AstFns(dir) {
+		for fnDecl, err := range AstFns(dir, patterns...) {
 			if err != nil {
 				yield(GetOptFn{}, err)
 				return
 			}
 			getOptFn := GetOptFn{FnDecl: fnDecl}
 
-			x := fnDecl.Node.(*ast.FuncDecl)
-
-			getOptFn.Description = strings.TrimSpace(getOptFn.Description)
-
 			// Expect function of type:
 			// func Name(opt *getoptions.GetOpt) getoptions.CommandFn
-
-			// Check Params
-			// Expect opt *getoptions.GetOpt
-			if len(x.Type.Params.List) != 1 {
+			getOptFn.OptFieldName, err = taskSignature(fnDecl)
+			if err != nil {
 				continue
 			}
-			for _, param := range x.Type.Params.List {
-				name := param.Names[0].Name
-				var buf bytes.Buffer
-				printer.Fprint(&buf, fnDecl.ParsedFile.fset, param.Type)
-				// Logger.Printf("name: %s, %s\n", name, buf.String())
-				if buf.String() != "*getoptions.GetOpt" {
-					continue LOOP
-				}
-				getOptFn.OptFieldName = name
-			}
 
-			// Check Results
-			// Expect getoptions.CommandFn
-			if len(x.Type.Results.List) != 1 {
-				continue
-			}
-			for _, result := range x.Type.Results.List {
-				var buf bytes.Buffer
-				printer.Fprint(&buf, fnDecl.ParsedFile.fset, result.Type)
-				// Logger.Printf("result: %s\n", buf.String())
-				if buf.String() != "getoptions.CommandFn" {
-					continue LOOP
-				}
+			getOptFn.Directives, err = parseDirectives(fnDecl)
+			if err != nil {
+				yield(getOptFn, err)
+				return
 			}
 
-			// TODO: The yield probably goes here
-			// Add function to OptTree
-			if getOptFn.Description != "" {
-				// Logger.Printf("description '%s'\n", description)
-				if re.MatchString(getOptFn.Description) {
-					// Get first word from string
-					getOptFn.DescName = strings.Split(getOptFn.Description, " ")[0]
-					getOptFn.Description = strings.TrimPrefix(getOptFn.Description, getOptFn.DescName+" -")
-					getOptFn.Description = strings.TrimSpace(getOptFn.Description)
-				}
-			} else {
-				getOptFn.DescName = camelToKebab(getOptFn.Name)
-			}
+			getOptFn.DescName, getOptFn.Description = parseDescription(getOptFn.Name, getOptFn.Description)
 			if !yield(getOptFn, nil) {
 				return
 			}
 		}
 	}
 }
+
+// taskSignature - Checks that the function has the bake task signature:
+//
+//	func Name(opt *getoptions.GetOpt) getoptions.CommandFn
+//
+// Returns the name of the opt parameter or the reason the function doesn't match.
+func taskSignature(fnDecl FnDecl) (string, error) {
+	x := fnDecl.Node.(*ast.FuncDecl)
+	if x.Recv != nil {
+		return "", fmt.Errorf("methods are not loaded as tasks")
+	}
+	if !x.Name.IsExported() {
+		return "", fmt.Errorf("function is not exported")
+	}
+
+	// Check Params
+	// Expect opt *getoptions.GetOpt
+	if len(x.Type.Params.List) != 1 || len(x.Type.Params.List[0].Names) > 1 {
+		return "", fmt.Errorf("expected a single parameter of type *getoptions.GetOpt")
+	}
+	param := x.Type.Params.List[0]
+	if t := exprString(fnDecl, param.Type); t != "*getoptions.GetOpt" {
+		return "", fmt.Errorf("parameter type is '%s', expected '*getoptions.GetOpt'", t)
+	}
+
+	// Check Results
+	// Expect getoptions.CommandFn
+	if x.Type.Results == nil || len(x.Type.Results.List) == 0 {
+		return "", fmt.Errorf("missing return type 'getoptions.CommandFn'")
+	}
+	if len(x.Type.Results.List) != 1 || len(x.Type.Results.List[0].Names) > 1 {
+		return "", fmt.Errorf("expected a single return value of type 'getoptions.CommandFn'")
+	}
+	if t := exprString(fnDecl, x.Type.Results.List[0].Type); t != "getoptions.CommandFn" {
+		return "", fmt.Errorf("return type is '%s', expected 'getoptions.CommandFn'", t)
+	}
+
+	// Tasks without options can leave the parameter unnamed
+	if len(param.Names) == 0 {
+		return "_", nil
+	}
+	return param.Names[0].Name, nil
+}
+
+func exprString(fnDecl FnDecl, expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fnDecl.ParsedFile.fset, expr)
+	return buf.String()
+}
+
+// parseDescription - Splits the description into the command name and the description.
+//
+//	// say:hello - This is a greeting
+//
+// If the description doesn't have a command name, the function name is converted to kebab case.
+func parseDescription(fnName, description string) (string, string) {
+	description = strings.TrimSpace(description)
+	if !descriptionRe.MatchString(description) {
+		return camelToKebab(fnName), description
+	}
+	// Get first word from string
+	descName := strings.Split(description, " ")[0]
+	description = strings.TrimPrefix(description, descName+" -")
+	return descName, strings.TrimSpace(description)
+}
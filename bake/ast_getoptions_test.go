@@ -0,0 +1,128 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func parseTestFnDecl(t *testing.T, src string) FnDecl {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, decl := range f.Decls {
+		if x, ok := decl.(*ast.FuncDecl); ok {
+			return FnDecl{
+				Name:        x.Name.Name,
+				Description: x.Doc.Text(),
+				Node:        x,
+				ParsedFile:  ParsedFile{file: "main.go", fset: fset, f: f},
+			}
+		}
+	}
+	t.Fatalf("no function found")
+	return FnDecl{}
+}
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		directives  Directives
+		description string
+		err         bool
+	}{
+		{
+			name: "no directives",
+			src: `package main
+// say:hello - This is a greeting
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			directives:  Directives{},
+			description: "say:hello - This is a greeting\n",
+		},
+		{
+			name: "timeout",
+			src: `package main
+// say:hello - This is a greeting
+//
+//bake:timeout 10m
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			directives:  Directives{Timeout: "10m"},
+			description: "say:hello - This is a greeting\n",
+		},
+		{
+			name: "depends",
+			src: `package main
+// deploy - Deploys
+//bake:depends build:go build:diagram
+//bake:depends test
+func Deploy(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			directives:  Directives{Depends: []string{"build:go", "build:diagram", "test"}},
+			description: "deploy - Deploys\n",
+		},
+		{
+			name: "env",
+			src: `package main
+// deploy - Deploys
+//bake:env build.env deploy.env
+func Deploy(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			directives:  Directives{Env: []string{"build.env", "deploy.env"}},
+			description: "deploy - Deploys\n",
+		},
+		{
+			name: "env without files",
+			src: `package main
+//bake:env
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			err: true,
+		},
+		{
+			name: "invalid timeout",
+			src: `package main
+//bake:timeout 10
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			err: true,
+		},
+		{
+			name: "unknown directive",
+			src: `package main
+//bake:unknown value
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn { return nil }`,
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fnDecl := parseTestFnDecl(t, tt.src)
+			got, err := parseDirectives(fnDecl)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.directives) {
+				t.Errorf("got %v, want %v", got, tt.directives)
+			}
+			if fnDecl.Description != tt.description {
+				t.Errorf("description got %q, want %q", fnDecl.Description, tt.description)
+			}
+		})
+	}
+}
@@ -19,9 +19,10 @@ import (
 	"github.com/DavidGamba/go-getoptions"
 )
 
-func addOptionsToCMD(getOptFn GetOptFn, cmd *getoptions.GetOpt, name string) error {
+func addOptionsToCMD(getOptFn GetOptFn, cmd *getoptions.GetOpt, name string) ([]Option, error) {
 	Logger.Printf("Adding options to %s\n", name)
 
+	options := []Option{}
 	var outerErr error
 	// Check for Expressions of opt type
 	ast.Inspect(getOptFn.Node, func(n ast.Node) bool {
@@ -61,39 +62,45 @@ func addOptionsToCMD(getOptFn GetOptFn, cmd *getoptions.GetOpt, name string) err
 
 						switch fun.Sel.Name {
 						case "Bool", "String", "StringOptional", "Int", "IntOptional", "Increment", "Float64", "Float64Optional":
-							mfns := []getoptions.ModifyFn{}
-							if len(x.Args) > 2 {
-								mfns = handleOptionModifiers(cmd, getOptFn.OptFieldName, x.Args[2:])
-							}
 							name, defaultValue, err := extractNameAndDefault(n, 0)
 							if err != nil {
 								outerErr = err
 								return false
 							}
+							option := Option{Name: name, Type: optionType(fun.Sel.Name), Default: defaultValue, method: fun.Sel.Name}
+							mfns := []getoptions.ModifyFn{}
+							if len(x.Args) > 2 {
+								mfns = handleOptionModifiers(cmd, getOptFn.OptFieldName, x.Args[2:], &option)
+							}
 							optionTypeSwitch(cmd, fun.Sel.Name, name, defaultValue, mfns)
+							options = append(options, option)
 
 						case "BoolVar", "StringVar", "StringVarOptional", "IntVar", "IntVarOptional", "IncrementVar", "Float64Var", "Float64VarOptional":
-							mfns := []getoptions.ModifyFn{}
-							if len(x.Args) > 3 {
-								mfns = handleOptionModifiers(cmd, getOptFn.OptFieldName, x.Args[3:])
-							}
 							name, defaultValue, err := extractNameAndDefault(n, 1)
 							if err != nil {
 								outerErr = err
 								return false
 							}
-							optionTypeSwitch(cmd, fun.Sel.Name, name, defaultValue, mfns)
-						case "StringSliceVar", "StringMapVar", "IntSliceVar", "Float64SliceVar":
+							option := Option{Name: name, Type: optionType(fun.Sel.Name), Default: defaultValue, method: fun.Sel.Name}
 							mfns := []getoptions.ModifyFn{}
-							if len(x.Args) > 4 {
-								mfns = handleOptionModifiers(cmd, getOptFn.OptFieldName, x.Args[4:])
+							if len(x.Args) > 3 {
+								mfns = handleOptionModifiers(cmd, getOptFn.OptFieldName, x.Args[3:], &option)
 							}
+							optionTypeSwitch(cmd, fun.Sel.Name, name, defaultValue, mfns)
+							options = append(options, option)
+						case "StringSliceVar", "StringMapVar", "IntSliceVar", "Float64SliceVar":
 							name, defaultValue, err := extractNameAndDefault(n, 1)
 							if err != nil {
 								outerErr = err
 								return false
 							}
+							option := Option{Name: name, Type: optionType(fun.Sel.Name), method: fun.Sel.Name}
+							mfns := []getoptions.ModifyFn{}
+							if len(x.Args) > 4 {
+								mfns = handleOptionModifiers(cmd, getOptFn.OptFieldName, x.Args[4:], &option)
+							}
 							optionTypeSwitch(cmd, fun.Sel.Name, name, defaultValue, mfns)
+							options = append(options, option)
 						}
 
 						return false
@@ -104,7 +111,57 @@ func addOptionsToCMD(getOptFn GetOptFn, cmd *getoptions.GetOpt, name string) err
 		}
 		return true
 	})
-	return outerErr
+	return options, outerErr
+}
+
+// optionType - Returns the value type of the option for the given getoptions method.
+func optionType(identifierName string) string {
+	switch identifierName {
+	case "Bool", "BoolVar":
+		return "bool"
+	case "String", "StringVar", "StringOptional", "StringVarOptional":
+		return "string"
+	case "Int", "IntVar", "IntOptional", "IntVarOptional", "Increment", "IncrementVar":
+		return "int"
+	case "Float64", "Float64Var", "Float64Optional", "Float64VarOptional":
+		return "float64"
+	case "StringSliceVar":
+		return "[]string"
+	case "IntSliceVar":
+		return "[]int"
+	case "Float64SliceVar":
+		return "[]float64"
+	case "StringMapVar":
+		return "map[string]string"
+	}
+	return ""
+}
+
+// addOption - Adds a previously parsed option to the command.
+func addOption(cmd *getoptions.GetOpt, option Option) {
+	mfns := []getoptions.ModifyFn{}
+	if len(option.Aliases) > 0 {
+		mfns = append(mfns, cmd.Alias(option.Aliases...))
+	}
+	if option.ArgName != "" {
+		mfns = append(mfns, cmd.ArgName(option.ArgName))
+	}
+	if option.Description != "" {
+		mfns = append(mfns, cmd.Description(option.Description))
+	}
+	if option.Env != "" {
+		mfns = append(mfns, cmd.GetEnv(option.Env))
+	}
+	if option.Required {
+		mfns = append(mfns, cmd.Required())
+	}
+	if len(option.SuggestedValues) > 0 {
+		mfns = append(mfns, cmd.SuggestedValues(option.SuggestedValues...))
+	}
+	if len(option.ValidValues) > 0 {
+		mfns = append(mfns, cmd.ValidValues(option.ValidValues...))
+	}
+	optionTypeSwitch(cmd, option.method, option.Name, option.Default, mfns)
 }
 
 func optionTypeSwitch(cmd *getoptions.GetOpt, identifierName, name, defaultValue string, mfns []getoptions.ModifyFn) {
@@ -202,7 +259,7 @@ func extractDefault(args []ast.Expr, offset int) (string, error) {
 	return defaultValue, nil
 }
 
-func handleOptionModifiers(cmd *getoptions.GetOpt, optFieldName string, args []ast.Expr) []getoptions.ModifyFn {
+func handleOptionModifiers(cmd *getoptions.GetOpt, optFieldName string, args []ast.Expr, option *Option) []getoptions.ModifyFn {
 	mfns := []getoptions.ModifyFn{}
 	for _, arg := range args {
 		callE, ok := arg.(*ast.CallExpr)
@@ -238,24 +295,31 @@ func handleOptionModifiers(cmd *getoptions.GetOpt, optFieldName string, args []a
 		switch fun.Sel.Name {
 		case "Alias":
 			mfns = append(mfns, cmd.Alias(values...))
+			option.Aliases = append(option.Aliases, values...)
 		case "ArgName":
 			if len(values) > 0 {
 				mfns = append(mfns, cmd.ArgName(values[0]))
+				option.ArgName = values[0]
 			}
 		case "Description":
 			if len(values) > 0 {
 				mfns = append(mfns, cmd.Description(values[0]))
+				option.Description = values[0]
 			}
 		case "GetEnv":
 			if len(values) > 0 {
 				mfns = append(mfns, cmd.GetEnv(values[0]))
+				option.Env = values[0]
 			}
 		case "Required":
 			mfns = append(mfns, cmd.Required(values...))
+			option.Required = true
 		case "SuggestedValues":
 			mfns = append(mfns, cmd.SuggestedValues(values...))
+			option.SuggestedValues = append(option.SuggestedValues, values...)
 		case "ValidValues":
 			mfns = append(mfns, cmd.ValidValues(values...))
+			option.ValidValues = append(option.ValidValues, values...)
 		}
 	}
 	return mfns
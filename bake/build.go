@@ -9,9 +9,12 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
 	"text/template"
@@ -21,13 +24,19 @@ import (
 	"github.com/DavidGamba/dgtools/run"
 )
 
-func buildBinary(dir string) error {
+func buildBinary(dir string, importDirs []string) error {
 	files, modified, err := fsmodtime.Target(os.DirFS(dir),
 		[]string{"bake"},
 		[]string{"*.go", "go.mod", "go.sum"})
 	if err != nil {
 		return err
 	}
+	if !modified {
+		files, modified, err = importsModified(dir, "bake", importDirs)
+		if err != nil {
+			return err
+		}
+	}
 	if modified {
 		Logger.Printf("Found modifications on %v, rebuilding binary...\n", files)
 		_ = run.CMD("go", "get").Dir(dir).Log().Run()
@@ -40,6 +49,31 @@ func buildBinary(dir string) error {
 	return nil
 }
 
+// importsModified - Checks the imported task packages dirs for sources newer than the target in dir.
+// Packages from the module cache don't change, but local packages added with a replace directive do.
+func importsModified(dir, target string, importDirs []string) ([]string, bool, error) {
+	fi, err := os.Stat(filepath.Join(dir, target))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{target}, true, nil
+		}
+		return nil, false, err
+	}
+	for _, importDir := range importDirs {
+		files, modified, err := fsmodtime.TargetTime(os.DirFS(importDir), fi.ModTime(), []string{"*.go"})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to check '%s': %w", importDir, err)
+		}
+		if modified {
+			for i, file := range files {
+				files[i] = filepath.Join(importDir, file)
+			}
+			return files, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
 var ErrNotFound = fmt.Errorf("not found")
 
 // Tried to get the bake folder to be called bake but it conflicts with the source bake folder.
@@ -88,6 +122,12 @@ func GenerateMainFile(ot *OptTree, dir string) error {
 	if err != nil {
 		return err
 	}
+	if !modified {
+		files, modified, err = importsModified(dir, generatedMainFilename, ot.ImportDirs)
+		if err != nil {
+			return err
+		}
+	}
 
 	binaryExists := true
 	if _, err := os.Stat(filepath.Join(dir, "bake")); os.IsNotExist(err) {
@@ -104,8 +144,10 @@ func GenerateMainFile(ot *OptTree, dir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
-	data := map[string]string{
-		"Tree": ot.String(),
+	data := map[string]any{
+		"Tree":    ot.String(),
+		"Imports": ot.ImportsString(),
+		"RunCmd":  !ot.HasRunCommand(),
 	}
 	// get writer to write to main.go
 	w, err := os.Create(filepath.Join(dir, generatedMainFilename))
@@ -118,3 +160,22 @@ func GenerateMainFile(ot *OptTree, dir string) error {
 	}
 	return run.CMD("go", "fmt", generatedMainFilename).Dir(dir).Log().Run()
 }
+
+// templateNames - Returns the names declared by the generated main file.
+// They are read from the template itself so they can't drift from it.
+func templateNames() ([]string, error) {
+	tmpl, err := template.ParseFS(templates, "templates/main.go.gotmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, map[string]any{"Tree": "", "Imports": "", "RunCmd": true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), generatedMainFilename, b.Bytes(), 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated file: %w", err)
+	}
+	return declaredNames(f, true), nil
+}
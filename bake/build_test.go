@@ -0,0 +1,452 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// buildTestBakefiles - Writes the files into a bakefiles dir, generates the main file and builds the bake binary.
+// The go.mod, go.sum and go.work files are created if not given.
+func buildTestBakefiles(t *testing.T, files map[string]string) (string, *OptTree) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	// The bakefiles are their own workspace, keep the outer go env from interfering
+	t.Setenv("GOWORK", "")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		files["go.mod"] = "module bake\n\ngo 1.23\n\nrequire github.com/DavidGamba/go-getoptions v0.30.0\n"
+	}
+	if _, ok := files["go.sum"]; !ok {
+		files["go.sum"] = getoptionsGoSum(t, "go.sum")
+	}
+	if _, ok := files["go.work"]; !ok {
+		files["go.work"] = "go 1.23\n\nuse .\n"
+	}
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	ot, err := LoadAst(context.Background(), getoptions.New(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = GenerateMainFile(ot, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = buildBinary(dir, ot.ImportDirs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return dir, ot
+}
+
+// getoptionsGoSum - Returns the go-getoptions go.sum lines from bake's own go.sum.
+func getoptionsGoSum(t *testing.T, goSum string) string {
+	t.Helper()
+	fh, err := os.Open(goSum)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer fh.Close()
+	out := ""
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "github.com/DavidGamba/go-getoptions ") {
+			out += scanner.Text() + "\n"
+		}
+	}
+	return out
+}
+
+// runTestBakefiles - Runs the bake binary with the env file variables, like RunBinary does.
+func runTestBakefiles(t *testing.T, dir string, ot *OptTree, args ...string) string {
+	t.Helper()
+	env, err := ot.BakeEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cmd := exec.Command(filepath.Join(dir, "bake"), args...)
+	cmd.Env = append(os.Environ(), "BAKE_ENV="+env)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	return string(out)
+}
+
+func TestBuildUserRunTask(t *testing.T) {
+	dir, ot := buildTestBakefiles(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// run - Runs the service
+func Run(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Println("user run task")
+		return nil
+	}
+}
+
+// test - Runs the tests
+func Test(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Println("test task")
+		return nil
+	}
+}
+`,
+	})
+	if out := runTestBakefiles(t, dir, ot, "run"); out != "user run task\n" {
+		t.Errorf("got %q, want the user run task output", out)
+	}
+	if out := runTestBakefiles(t, dir, ot, "test"); out != "test task\n" {
+		t.Errorf("got %q, want the test task output", out)
+	}
+}
+
+func TestBuildBuiltinRun(t *testing.T) {
+	dir, ot := buildTestBakefiles(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// test - Runs the tests
+func Test(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Println("test task")
+		return nil
+	}
+}
+`,
+	})
+	out := runTestBakefiles(t, dir, ot, "run", "test")
+	if !strings.Contains(out, "test task") || !strings.Contains(out, "Summary:") {
+		t.Errorf("got %q, want the test task output and the run summary", out)
+	}
+}
+
+func TestBuildEnvFiles(t *testing.T) {
+	t.Setenv("BAKE_TEST_NAME", "bake")
+	// The env file paths are relative to the current dir
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	envDir := t.TempDir()
+	err = os.Chdir(envDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	err = os.WriteFile("build.env", []byte("GREETING=hello\nTARGET=$BAKE_TEST_NAME\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile("deploy.env", []byte("TARGET=prod-$TARGET\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	goSum := getoptionsGoSum(t, filepath.Join(wd, "go.sum"))
+	dir, ot := buildTestBakefiles(t, map[string]string{
+		"go.mod": "module bake\n\ngo 1.23\n\n// bake:env build.env\n\nrequire github.com/DavidGamba/go-getoptions v0.30.0\n",
+		"go.sum": goSum,
+		"main.go": `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// build - Builds
+func Build(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Printf("%s %s\n", os.Getenv("GREETING"), os.Getenv("TARGET"))
+		return nil
+	}
+}
+
+// deploy - Deploys
+//
+//bake:env deploy.env
+func Deploy(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Printf("%s %s\n", os.Getenv("GREETING"), os.Getenv("TARGET"))
+		return nil
+	}
+}
+`,
+	})
+	if out := runTestBakefiles(t, dir, ot, "build"); out != "hello bake\n" {
+		t.Errorf("got %q, want %q", out, "hello bake\n")
+	}
+	if out := runTestBakefiles(t, dir, ot, "deploy"); out != "hello prod-bake\n" {
+		t.Errorf("got %q, want %q", out, "hello prod-bake\n")
+	}
+	// The binary reports the same values as _bake env
+	vars, err := ot.TaskEnv("deploy")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := envVarsMap(vars)["TARGET"]; got != "prod-bake" {
+		t.Errorf("got %q, want %q", got, "prod-bake")
+	}
+}
+
+func TestBuildImportTemplateName(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	goSum := getoptionsGoSum(t, filepath.Join(wd, "go.sum"))
+
+	// Task package named like one of the generated main file imports
+	tasksDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(tasksDir, "go.mod"), []byte("module example.com/log\n\ngo 1.23\n\nrequire github.com/DavidGamba/go-getoptions v0.30.0\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(tasksDir, "go.sum"), []byte(goSum), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(tasksDir, "log.go"), []byte(`package log
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// rotate - Rotates the logs
+func Rotate(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Println("rotate task")
+		return nil
+	}
+}
+`), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dir, ot := buildTestBakefiles(t, map[string]string{
+		"go.mod": "module bake\n\ngo 1.23\n\n// bake:import example.com/log\n\nrequire (\n\tgithub.com/DavidGamba/go-getoptions v0.30.0\n\texample.com/log v0.0.0\n)\n\nreplace example.com/log => " + tasksDir + "\n",
+		"go.sum": goSum,
+		"main.go": `package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// Package level name that the import alias can't use either
+var log2 = "build task"
+
+// build - Builds
+func Build(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Println(log2)
+		return nil
+	}
+}
+`,
+	})
+	if got := ot.Imports["example.com/log"]; got != "log3" {
+		t.Errorf("got alias %q, want log3", got)
+	}
+	if out := runTestBakefiles(t, dir, ot, "rotate"); out != "rotate task\n" {
+		t.Errorf("got %q, want the rotate task output", out)
+	}
+	if out := runTestBakefiles(t, dir, ot, "build"); out != "build task\n" {
+		t.Errorf("got %q, want the build task output", out)
+	}
+
+	// Changes to the imported package rebuild the binary
+	if !slices.Contains(ot.ImportDirs, tasksDir) {
+		t.Errorf("got import dirs %v, want %s", ot.ImportDirs, tasksDir)
+	}
+	src, err := os.ReadFile(filepath.Join(tasksDir, "log.go"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(tasksDir, "log.go"), bytes.ReplaceAll(src, []byte("rotate task"), []byte("rotate task v2")), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	future := time.Now().Add(time.Minute)
+	err = os.Chtimes(filepath.Join(tasksDir, "log.go"), future, future)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = GenerateMainFile(ot, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = buildBinary(dir, ot.ImportDirs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out := runTestBakefiles(t, dir, ot, "rotate"); out != "rotate task v2\n" {
+		t.Errorf("got %q, want the updated rotate task output", out)
+	}
+}
+
+func TestBuildBuiltinRunOutput(t *testing.T) {
+	dir, ot := buildTestBakefiles(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// a - Prints
+func A(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Println("from a")
+		c := exec.Command("sh", "-c", "echo from a child >&2")
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		return c.Run()
+	}
+}
+
+// c - Fails
+//
+//bake:depends a
+func C(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		return ExitCode(7, fmt.Errorf("c failed"))
+	}
+}
+`,
+	})
+	env, err := ot.BakeEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cmd := exec.Command(filepath.Join(dir, "bake"), "run", "c")
+	cmd.Env = append(os.Environ(), "BAKE_ENV="+env)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 7 {
+		t.Errorf("got %v, want exit code 7\n%s", err, out)
+	}
+	for _, s := range []string{"[a] from a\n", "[a] from a child\n", "[c] ERROR: c failed\n"} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("output missing %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(string(out), "Running Task") {
+		t.Errorf("unexpected dag logs:\n%s", out)
+	}
+}
+
+func TestBuildBuiltinRunTaskEnv(t *testing.T) {
+	// The env file paths are relative to the current dir
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	err = os.WriteFile("a.env", []byte("TARGET=a\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile("b.env", []byte("TARGET=b\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	task := func(name, directive string) string {
+		return fmt.Sprintf(`
+// %s - Prints the target
+//%s
+func %s(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Printf("target=%%s\n", os.Getenv("TARGET"))
+		return nil
+	}
+}
+`, strings.ToLower(name), directive, name)
+	}
+	goSum := getoptionsGoSum(t, filepath.Join(wd, "go.sum"))
+	dir, ot := buildTestBakefiles(t, map[string]string{
+		"go.sum": goSum,
+		"main.go": `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+` + task("A", "bake:env a.env") + task("B", "bake:env b.env") + task("C", ""),
+	})
+	out := runTestBakefiles(t, dir, ot, "run", "a", "b", "c")
+	for _, s := range []string{"[a] target=a\n", "[b] target=b\n", "[c] target=\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output missing %q:\n%s", s, out)
+		}
+	}
+}
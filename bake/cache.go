@@ -0,0 +1,180 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/DavidGamba/dgtools/fsmodtime"
+	"github.com/DavidGamba/go-getoptions"
+)
+
+const treeCacheFilename = "generated_bake_cache.json"
+
+// treeCache - Serialised OptTree.
+// The getoptions instances can't be serialised so the tree is rebuilt from the parsed task data.
+type treeCache struct {
+	Version    string            `json:"version"`
+	Imports    map[string]string `json:"imports"`
+	ImportDirs []string          `json:"import_dirs,omitempty"`
+	EnvFiles   []string          `json:"env_files,omitempty"`
+	Tasks      []cachedTask      `json:"tasks"`
+}
+
+type cachedTask struct {
+	Name        string         `json:"name"`
+	DescName    string         `json:"desc_name"`
+	Description string         `json:"description"`
+	Directives  Directives     `json:"directives"`
+	Options     []cachedOption `json:"options"`
+}
+
+type cachedOption struct {
+	Method string `json:"method"`
+	Option
+}
+
+// LoadAstCached - Loads the OptTree from the cache file if it is newer than the bakefiles sources.
+// Otherwise it parses the sources with LoadAst and updates the cache.
+func LoadAstCached(ctx context.Context, opt *getoptions.GetOpt, dir string) (*OptTree, error) {
+	ot, err := loadTreeCache(opt, dir)
+	if err == nil {
+		return ot, nil
+	}
+	Logger.Printf("Tree cache not used: %s\n", err)
+
+	ot, err = LoadAst(ctx, opt, dir)
+	if err != nil {
+		return ot, err
+	}
+	err = saveTreeCache(ot, dir)
+	if err != nil {
+		return ot, err
+	}
+	return ot, nil
+}
+
+var errStaleCache = fmt.Errorf("stale cache")
+
+// treeCacheStale - Returns an error wrapping errStaleCache if the bakefiles sources or the imported task packages sources are newer than the cache file.
+func treeCacheStale(dir string, importDirs []string) error {
+	fi, err := os.Stat(filepath.Join(dir, treeCacheFilename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: cache file not found", errStaleCache)
+		}
+		return err
+	}
+	files, modified, err := fsmodtime.TargetTime(os.DirFS(dir), fi.ModTime(), []string{"*.go", "go.mod", "go.sum"})
+	if err != nil {
+		return err
+	}
+	if !modified {
+		files, modified, err = importsModified(dir, treeCacheFilename, importDirs)
+		if err != nil {
+			return err
+		}
+	}
+	if modified {
+		return fmt.Errorf("%w: found modifications on %v", errStaleCache, files)
+	}
+	return nil
+}
+
+func loadTreeCache(opt *getoptions.GetOpt, dir string) (*OptTree, error) {
+	data, err := os.ReadFile(filepath.Join(dir, treeCacheFilename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: cache file not found", errStaleCache)
+		}
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+	tc := treeCache{}
+	err = json.Unmarshal(data, &tc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cache: %w", err)
+	}
+	if tc.Version != version {
+		return nil, fmt.Errorf("%w: created by bake version %s", errStaleCache, tc.Version)
+	}
+	// The import dirs are only known after reading the cache
+	err = treeCacheStale(dir, tc.ImportDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	ot := NewOptTree(opt)
+	for path, alias := range tc.Imports {
+		ot.Imports[path] = alias
+	}
+	ot.ImportDirs = tc.ImportDirs
+	ot.EnvFiles = tc.EnvFiles
+	for _, task := range tc.Tasks {
+		cmd, err := ot.AddCommand(task.Name, task.DescName, task.Description, task.Directives)
+		if err != nil {
+			return nil, err
+		}
+		options := []Option{}
+		for _, o := range task.Options {
+			o.Option.method = o.Method
+			addOption(cmd, o.Option)
+			options = append(options, o.Option)
+		}
+		ot.Node(task.DescName).Options = options
+	}
+	return ot, nil
+}
+
+func saveTreeCache(ot *OptTree, dir string) error {
+	tc := treeCache{
+		Version:    version,
+		Imports:    ot.Imports,
+		ImportDirs: ot.ImportDirs,
+		EnvFiles:   ot.EnvFiles,
+		Tasks:      []cachedTask{},
+	}
+	for _, node := range ot.Tasks() {
+		task := cachedTask{
+			Name:        node.Name,
+			DescName:    node.FullName,
+			Description: node.Description,
+			Directives:  node.Directives,
+			Options:     []cachedOption{},
+		}
+		for _, o := range node.Options {
+			task.Options = append(task.Options, cachedOption{Method: o.method, Option: o})
+		}
+		tc.Tasks = append(tc.Tasks, task)
+	}
+	data, err := json.Marshal(tc)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, treeCacheFilename), data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+func InvalidateTreeCache(dir string) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		fmt.Printf("Removing bake tree cache...\n")
+		err := os.Remove(filepath.Join(dir, treeCacheFilename))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove cache: %w", err)
+		}
+		return nil
+	}
+}
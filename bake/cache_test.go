@@ -0,0 +1,111 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func TestTreeCache(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	err := os.WriteFile(src, []byte("package main\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	err = os.Chtimes(src, old, old)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = loadTreeCache(getoptions.New(), dir)
+	if !errors.Is(err, errStaleCache) {
+		t.Fatalf("expected stale cache error, got %v", err)
+	}
+
+	ot := NewOptTree(getoptions.New())
+	cmd, err := ot.AddCommand("Hello", "say:hello", "This is a greeting", Directives{Timeout: "1m", Depends: []string{"build"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ot.Node("say:hello").Options = []Option{
+		{Name: "lang", Type: "string", Default: "en", ValidValues: []string{"en", "es"}, method: "StringVar"},
+	}
+	addOption(cmd, ot.Node("say:hello").Options[0])
+	ot.AddImport("github.com/org/tasks", "tasks")
+	importDir := t.TempDir()
+	importSrc := filepath.Join(importDir, "tasks.go")
+	err = os.WriteFile(importSrc, []byte("package tasks\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.Chtimes(importSrc, old, old)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ot.ImportDirs = []string{importDir}
+
+	err = saveTreeCache(ot, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := loadTreeCache(getoptions.New(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got.TaskInfos(), ot.TaskInfos()) {
+		t.Errorf("got %v, want %v", got.TaskInfos(), ot.TaskInfos())
+	}
+	if !reflect.DeepEqual(got.Imports, ot.Imports) {
+		t.Errorf("got %v, want %v", got.Imports, ot.Imports)
+	}
+	if !reflect.DeepEqual(got.ImportDirs, ot.ImportDirs) {
+		t.Errorf("got %v, want %v", got.ImportDirs, ot.ImportDirs)
+	}
+	if got.Node("say:hello").Options[0].method != "StringVar" {
+		t.Errorf("option method not restored")
+	}
+
+	// Imported package sources newer than the cache
+	err = os.Chtimes(importSrc, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = loadTreeCache(getoptions.New(), dir)
+	if !errors.Is(err, errStaleCache) {
+		t.Fatalf("expected stale cache error, got %v", err)
+	}
+	err = os.Chtimes(importSrc, old, old)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = loadTreeCache(getoptions.New(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Sources newer than the cache
+	err = os.Chtimes(src, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = loadTreeCache(getoptions.New(), dir)
+	if !errors.Is(err, errStaleCache) {
+		t.Fatalf("expected stale cache error, got %v", err)
+	}
+}
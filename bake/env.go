@@ -0,0 +1,176 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/DavidGamba/dgtools/fsmodtime"
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// EnvVar - Variable loaded from an env file.
+type EnvVar struct {
+	Name  string
+	Value string
+	File  string
+}
+
+var envCommentRe = regexp.MustCompile(`^\s*#|^\s*//`)
+
+// loadEnvFiles - Reads the KEY=value lines of the given env files in order.
+// Files that don't exist are skipped.
+// Blank lines and lines starting with # or // are ignored, an optional export prefix is allowed.
+//
+// Values are expanded with fsmodtime.ExpandEnv semantics, they can refer to the variables in vars,
+// to variables defined earlier in the files, or to the process environment.
+// Later definitions override earlier ones.
+func loadEnvFiles(vars []EnvVar, files ...string) ([]EnvVar, error) {
+	for _, file := range files {
+		fh, err := os.Open(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				Logger.Printf("Env file %s not found, skipping\n", file)
+				continue
+			}
+			return vars, fmt.Errorf("failed to open env file: %w", err)
+		}
+		scanner := bufio.NewScanner(fh)
+		n := 0
+		for scanner.Scan() {
+			n++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || envCommentRe.MatchString(line) {
+				continue
+			}
+			line = strings.TrimPrefix(line, "export ")
+			name, value, ok := strings.Cut(line, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				fh.Close()
+				return vars, fmt.Errorf("%s:%d: expected KEY=value", file, n)
+			}
+			value = unquoteEnvValue(strings.TrimSpace(value))
+			expanded, err := fsmodtime.ExpandEnv([]string{value}, envVarsMap(vars))
+			if err != nil {
+				fh.Close()
+				return vars, fmt.Errorf("%s:%d: failed to expand '%s': %w", file, n, name, err)
+			}
+			Logger.Printf("Adding to env from %s: %s\n", file, name)
+			vars = setEnvVar(vars, EnvVar{Name: name, Value: expanded[0], File: file})
+		}
+		err = scanner.Err()
+		fh.Close()
+		if err != nil {
+			return vars, fmt.Errorf("failed to read env file: %w", err)
+		}
+	}
+	return vars, nil
+}
+
+// unquoteEnvValue - Removes matching single or double quotes around the value.
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func envVarsMap(vars []EnvVar) map[string]string {
+	m := make(map[string]string, len(vars))
+	for _, v := range vars {
+		m[v.Name] = v.Value
+	}
+	return m
+}
+
+// setEnvVar - Appends the variable, removing any earlier definition so that the variables stay in load order.
+func setEnvVar(vars []EnvVar, v EnvVar) []EnvVar {
+	vars = slices.DeleteFunc(vars, func(e EnvVar) bool { return e.Name == v.Name })
+	return append(vars, v)
+}
+
+// TaskEnv - Returns the effective env file variables for the given task ID.
+// The global env files are loaded first and the task env files override them.
+// An empty task ID returns the variables of the global env files.
+func (ot *OptTree) TaskEnv(id string) ([]EnvVar, error) {
+	vars, err := loadEnvFiles(nil, ot.EnvFiles...)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return vars, nil
+	}
+	node := ot.Node(id)
+	if node == nil || node.Name == "" {
+		return nil, fmt.Errorf("task '%s' not found", id)
+	}
+	return loadEnvFiles(vars, node.Directives.Env...)
+}
+
+// bakeEnv - Env file variables passed to the task binary in the BAKE_ENV env var.
+// The binary sets them in its environment instead of reading the env files, so that tasks run with the values `bake _bake env` reports.
+type bakeEnv struct {
+	Global []EnvVar            // variables of the global env files
+	Tasks  map[string][]EnvVar // task ID to its effective variables, for the tasks with env files
+	Errors map[string]string   // task ID to the error loading its env files, reported when the task runs
+}
+
+// BakeEnv - Returns the JSON encoded env file variables for the task binary.
+func (ot *OptTree) BakeEnv() (string, error) {
+	global, err := ot.TaskEnv("")
+	if err != nil {
+		return "", err
+	}
+	env := bakeEnv{Global: global, Tasks: map[string][]EnvVar{}, Errors: map[string]string{}}
+	for _, node := range ot.Tasks() {
+		if len(node.Directives.Env) == 0 {
+			continue
+		}
+		vars, err := ot.TaskEnv(node.FullName)
+		if err != nil {
+			env.Errors[node.FullName] = err.Error()
+			continue
+		}
+		env.Tasks[node.FullName] = vars
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal env: %w", err)
+	}
+	return string(b), nil
+}
+
+// EnvRun - Prints the variables the env files add to the environment of the given task.
+//
+//	bake _bake env build:binary
+func EnvRun(ot *OptTree) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		vars, err := ot.TaskEnv(strings.Join(args, ":"))
+		if err != nil {
+			return err
+		}
+		file := ""
+		for _, v := range vars {
+			if v.File != file {
+				file = v.File
+				fmt.Printf("# %s\n", file)
+			}
+			fmt.Printf("%s=%s\n", v.Name, v.Value)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func TestLoadEnvFiles(t *testing.T) {
+	t.Setenv("BAKE_TEST_HOME", "/home/bake")
+	t.Setenv("HOME", "/home/bake")
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.env")
+	task := filepath.Join(dir, "task.env")
+	err := os.WriteFile(global, []byte(`# comment
+// comment
+
+export GREETING="hello"
+NAME='bake'
+BIN=~/bin
+`), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(task, []byte(`GREETING=$GREETING ${NAME}
+DIR=$BAKE_TEST_HOME/src
+`), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := loadEnvFiles(nil, global, filepath.Join(dir, "missing.env"), task)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []EnvVar{
+		{Name: "NAME", Value: "bake", File: global},
+		{Name: "BIN", Value: "/home/bake/bin", File: global},
+		{Name: "GREETING", Value: "hello bake", File: task},
+		{Name: "DIR", Value: "/home/bake/src", File: task},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for _, content := range []string{"NOT_A_VAR\n", "A=$BAKE_TEST_UNDEFINED_VAR\n"} {
+			file := filepath.Join(dir, "bad.env")
+			err := os.WriteFile(file, []byte(content), 0644)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			_, err = loadEnvFiles(nil, file)
+			if err == nil {
+				t.Errorf("expected error for %q, got nil", content)
+			}
+		}
+	})
+}
+
+func TestTaskEnv(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.env")
+	task := filepath.Join(dir, "task.env")
+	err := os.WriteFile(global, []byte("A=1\nB=2\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(task, []byte("B=3\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ot := NewOptTree(getoptions.New())
+	ot.EnvFiles = []string{global}
+	_, err = ot.AddCommand("Binary", "build:binary", "", Directives{Env: []string{task}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := ot.TaskEnv("build:binary")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []EnvVar{{Name: "A", Value: "1", File: global}, {Name: "B", Value: "3", File: task}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+
+	_, err = ot.TaskEnv("build")
+	if err == nil {
+		t.Errorf("expected error for a command without a task, got nil")
+	}
+
+	// Errors loading the env files of a task are reported when the task runs
+	bad := filepath.Join(dir, "bad.env")
+	err = os.WriteFile(bad, []byte("NOT_A_VAR\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = ot.AddCommand("Deploy", "deploy", "", Directives{Env: []string{bad}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s, err := ot.BakeEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	env := bakeEnv{}
+	err = json.Unmarshal([]byte(s), &env)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(env.Global, []EnvVar{{Name: "A", Value: "1", File: global}, {Name: "B", Value: "2", File: global}}) {
+		t.Errorf("unexpected global env: %v", env.Global)
+	}
+	if !reflect.DeepEqual(env.Tasks["build:binary"], expected) {
+		t.Errorf("got %v, want %v", env.Tasks["build:binary"], expected)
+	}
+	if env.Errors["deploy"] == "" {
+		t.Errorf("expected error for deploy, got none")
+	}
+}
@@ -0,0 +1,77 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Regex for import directive: // bake:import github.com/org/tasks
+var importDirectiveRe = regexp.MustCompile(`^\s*//\s*bake:import\s+(\S+)\s*$`)
+
+// Regex for env directive: // bake:env build.env [local.env]
+var envDirectiveRe = regexp.MustCompile(`^\s*//\s*bake:env\s+(\S.*?)\s*$`)
+
+// bakeImports - returns the task packages declared in the bakefiles go.mod with a bake:import directive.
+//
+//	module bake
+//
+//	// bake:import github.com/org/tasks
+//
+//	require github.com/org/tasks v0.1.0
+func bakeImports(dir string) ([]string, error) {
+	return goModDirectives(dir, importDirectiveRe)
+}
+
+// bakeEnvFiles - returns the env files declared in the bakefiles go.mod with a bake:env directive.
+// These are loaded for every task.
+//
+//	// bake:env build.env
+func bakeEnvFiles(dir string) ([]string, error) {
+	lines, err := goModDirectives(dir, envDirectiveRe)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, line := range lines {
+		files = append(files, strings.Fields(line)...)
+	}
+	return files, nil
+}
+
+// goModDirectives - returns the values of the go.mod comments matching re.
+func goModDirectives(dir string, re *regexp.Regexp) ([]string, error) {
+	fh, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open go.mod: %w", err)
+	}
+	defer fh.Close()
+
+	values := []string{}
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		m := re.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		values = append(values, m[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	return values, nil
+}
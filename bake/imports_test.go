@@ -0,0 +1,162 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBakeImports(t *testing.T) {
+	dir := t.TempDir()
+	goMod := `module bake
+
+go 1.23
+
+// bake:import github.com/org/tasks
+//bake:import github.com/org/other/tasks
+
+// github.com/org/not-an-import
+
+require (
+	github.com/org/tasks v0.1.0
+	github.com/org/other v0.2.0
+)
+`
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := bakeImports(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"github.com/org/tasks", "github.com/org/other/tasks"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+
+	got, err = bakeImports(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v, want none", got)
+	}
+}
+
+func TestBakeEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	goMod := `module bake
+
+go 1.23
+
+// bake:import github.com/org/tasks
+// bake:env build.env
+//bake:env local.env  other.env
+`
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := bakeEnvFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"build.env", "local.env", "other.env"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
+
+func TestOptTreeAddImport(t *testing.T) {
+	ot := NewOptTree(nil)
+	if got := ot.AddImport("github.com/org/tasks", "tasks"); got != "tasks" {
+		t.Errorf("got %s, want tasks", got)
+	}
+	if got := ot.AddImport("github.com/other/tasks", "tasks"); got != "tasks2" {
+		t.Errorf("got %s, want tasks2", got)
+	}
+	if got := ot.AddImport("github.com/org/tasks", "tasks"); got != "tasks" {
+		t.Errorf("got %s, want tasks", got)
+	}
+	expected := "tasks \"github.com/org/tasks\"\ntasks2 \"github.com/other/tasks\"\n"
+	if got := ot.ImportsString(); got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+}
+
+func TestOptTreeAddImportTemplateNames(t *testing.T) {
+	ot := NewOptTree(nil)
+	names, err := templateNames()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ot.ReserveNames(names...)
+	ot.ReserveNames("helpers")
+	if got := ot.AddImport("github.com/org/log", "log"); got != "log2" {
+		t.Errorf("got %s, want log2", got)
+	}
+	if got := ot.AddImport("github.com/org/logger", "Logger"); got != "Logger2" {
+		t.Errorf("got %s, want Logger2", got)
+	}
+	if got := ot.AddImport("github.com/org/helpers", "helpers"); got != "helpers2" {
+		t.Errorf("got %s, want helpers2", got)
+	}
+}
+
+func TestTemplateNames(t *testing.T) {
+	names, err := templateNames()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, name := range []string{"getoptions", "dag", "log", "Logger", "TM", "main", "loadFns"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("template name %s missing from %v", name, names)
+		}
+	}
+}
+
+func TestDeclaredNames(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	gopt "github.com/DavidGamba/go-getoptions"
+)
+
+type config struct{}
+
+var a, b = 1, 2
+
+const c = 3
+
+func helper() {}
+
+func (config) method() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := declaredNames(f, false)
+	want := []string{"config", "a", "b", "c", "helper"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	got = declaredNames(f, true)
+	want = append([]string{"fmt", "gopt"}, want...)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -9,18 +9,47 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"embed"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
 
 	"github.com/DavidGamba/dgtools/run"
 	"github.com/DavidGamba/go-getoptions"
 )
 
+//go:embed templates/init
+var initTemplates embed.FS
+
+const initTemplatesDir = "templates/init"
+
+// Files bake generates in the bakefiles dir that shouldn't be committed
+const gitignoreSnippet = `bake
+generated_bake.go
+generated_bake_cache.json
+`
+
 func initRun(dir string) getoptions.CommandFn {
 	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
-		err := initFn(opt)
+		if opt.Value("list-templates").(bool) {
+			names, err := initTemplateNames(opt.Value("template-dir").(string))
+			if err != nil {
+				return fmt.Errorf("failed to list templates: %w", err)
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return nil
+		}
+		err := initFn(ctx, opt)
 		if err != nil {
 			return fmt.Errorf("failed to inspect package: %w", err)
 		}
@@ -28,35 +57,196 @@ func initRun(dir string) getoptions.CommandFn {
 	}
 }
 
-func initFn(opt *getoptions.GetOpt) error {
+func embeddedInitTemplateNames() []string {
+	names, _ := initTemplateNames("")
+	return names
+}
+
+// initTemplateNames - Returns the names of the embedded templates and the user templates in templateDir.
+func initTemplateNames(templateDir string) ([]string, error) {
+	names := []string{}
+	entries, err := initTemplates.ReadDir(initTemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if templateDir != "" {
+		entries, err := os.ReadDir(templateDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() && !slices.Contains(names, e.Name()) {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// initTemplateFS - Returns the template with the given name.
+// User templates in templateDir take precedence over the embedded ones.
+func initTemplateFS(name, templateDir string) (fs.FS, error) {
+	if templateDir != "" {
+		dir := filepath.Join(templateDir, name)
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return os.DirFS(dir), nil
+		}
+	}
+	fsys, err := fs.Sub(initTemplates, path.Join(initTemplatesDir, name))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(fsys, "."); err != nil {
+		return nil, fmt.Errorf("template '%s' not found", name)
+	}
+	return fsys, nil
+}
+
+type initFile struct {
+	path    string
+	content []byte
+}
+
+// validateInitName - Checks that the project name can be rendered into the templates.
+// The embedded templates quote it in Go string literals but it is also used in comments, where a new line breaks the code.
+func validateInitName(name string) error {
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return fmt.Errorf("invalid project name %q: the current dir name can't contain control characters", name)
+	}
+	return nil
+}
+
+// renderInitTemplate - Renders the template files.
+// The paths are relative to the project root and the .gotmpl suffix is removed.
+func renderInitTemplate(fsys fs.FS, data any) ([]initFile, error) {
+	files := []initFile{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(p, ".gotmpl") {
+			tmpl, err := template.New(p).Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("failed to parse template '%s': %w", p, err)
+			}
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, data)
+			if err != nil {
+				return fmt.Errorf("failed to execute template '%s': %w", p, err)
+			}
+			content = buf.Bytes()
+			p = strings.TrimSuffix(p, ".gotmpl")
+		}
+		files = append(files, initFile{path: filepath.FromSlash(p), content: content})
+		return nil
+	})
+	return files, err
+}
+
+func initFn(ctx context.Context, opt *getoptions.GetOpt) error {
 	Logger.Printf("Initializing bake project in\n")
 	dir := "bakefiles"
-	os.MkdirAll(dir, 0755)
+	templateName := opt.Value("template").(string)
+	dryRun := opt.Value("dry-run").(bool)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	files := []initFile{}
+	if templateName != "" {
+		fsys, err := initTemplateFS(templateName, opt.Value("template-dir").(string))
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(wd)
+		err = validateInitName(name)
+		if err != nil {
+			return err
+		}
+		data := map[string]string{
+			"Name": name,
+		}
+		files, err = renderInitTemplate(fsys, data)
+		if err != nil {
+			return err
+		}
+	}
+	files = append(files, initFile{path: filepath.Join(dir, ".gitignore"), content: []byte(gitignoreSnippet)})
 
-	_ = run.CMD("go", "mod", "init", "bake").Dir(dir).Log().Run()
-	// if err != nil {
-	// 	return fmt.Errorf("failed to initialize go mod: %w", err)
-	// }
-	_ = run.CMD("go", "work", "init").Dir(dir).Log().Env("GOWORK=off").Run()
-	// if err != nil {
-	// 	return fmt.Errorf("failed to initialize go work: %w", err)
-	// }
-	_ = run.CMD("go", "work", "use", ".").Dir(dir).Log().Run()
-	// if err != nil {
-	// 	return fmt.Errorf("failed to configure go work: %w", err)
-	// }
 	// github.com/DavidGamba/dgtools/buildutils
 	// github.com/DavidGamba/dgtools/fsmodtime
 	// github.com/DavidGamba/dgtools/run
 	// github.com/DavidGamba/go-getoptions
+	cmds := []*run.RunInfo{
+		run.CMD("go", "mod", "init", "bake").Dir(dir).Log(),
+		run.CMD("go", "work", "init").Dir(dir).Log().Env("GOWORK=off"),
+		run.CMD("go", "work", "use", ".").Dir(dir).Log(),
+		run.CMD("go", "get", "-u", "github.com/DavidGamba/dgtools/buildutils").Dir(dir).Log(),
+		run.CMD("go", "get", "-u", "github.com/DavidGamba/dgtools/fsmodtime").Dir(dir).Log(),
+		run.CMD("go", "get", "-u", "github.com/DavidGamba/dgtools/run").Dir(dir).Log(),
+		run.CMD("go", "get", "-u", "github.com/DavidGamba/go-getoptions").Dir(dir).Log(),
+	}
+
+	if dryRun {
+		fmt.Printf("Directory: %s\n", dir)
+		for _, f := range files {
+			status := ""
+			if _, err := os.Stat(f.path); err == nil {
+				status = " (exists, skipped)"
+			}
+			fmt.Printf("File: %s%s\n", f.path, status)
+		}
+		for _, c := range cmds {
+			fmt.Printf("Run: %s (in %s)\n", strings.Join(c.Cmd, " "), dir)
+		}
+		fmt.Printf("File: %s\n", filepath.Join(dir, generatedMainFilename))
+		return nil
+	}
 
-	_ = run.CMD("go", "get", "-u", "github.com/DavidGamba/dgtools/buildutils").Dir(dir).Log().Run()
-	_ = run.CMD("go", "get", "-u", "github.com/DavidGamba/dgtools/fsmodtime").Dir(dir).Log().Run()
-	_ = run.CMD("go", "get", "-u", "github.com/DavidGamba/dgtools/run").Dir(dir).Log().Run()
-	_ = run.CMD("go", "get", "-u", "github.com/DavidGamba/go-getoptions").Dir(dir).Log().Run()
+	os.MkdirAll(dir, 0755)
+
+	// Errors are ignored to allow re-running init on an existing project
+	for _, c := range cmds {
+		_ = c.Run()
+	}
+
+	for _, f := range files {
+		if _, err := os.Stat(f.path); err == nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s already exists, skipping\n", f.path)
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(f.path), 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create dir: %w", err)
+		}
+		err = os.WriteFile(f.path, f.content, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		fmt.Printf("Created %s\n", f.path)
+	}
 
 	ot := NewOptTree(opt)
-	err := GenerateMainFile(ot, dir)
+	if templateName != "" {
+		ot, err = LoadAst(ctx, getoptions.New(), dir)
+		if err != nil {
+			return fmt.Errorf("failed to load tasks: %w", err)
+		}
+	}
+	err = GenerateMainFile(ot, dir)
 	if err != nil {
 		return fmt.Errorf("failed to generate file: %w", err)
 	}
@@ -0,0 +1,139 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInitTemplates(t *testing.T) {
+	names, err := initTemplateNames("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"go-service", "homebrew", "terraform", "website"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("got %v, want %v", names, expected)
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			fsys, err := initTemplateFS(name, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			files, err := renderInitTemplate(fsys, map[string]string{"Name": "project"})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(files) == 0 {
+				t.Fatalf("no files rendered")
+			}
+			for _, f := range files {
+				if strings.HasSuffix(f.path, ".gotmpl") {
+					t.Errorf("template suffix not removed: %s", f.path)
+				}
+				if strings.Contains(string(f.content), "{{") {
+					t.Errorf("unrendered template action in %s", f.path)
+				}
+			}
+		})
+	}
+
+	// Quotes and backslashes in the dir name must not break the generated code
+	for _, name := range names {
+		t.Run(name+" quoted name", func(t *testing.T) {
+			fsys, err := initTemplateFS(name, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			files, err := renderInitTemplate(fsys, map[string]string{"Name": `my "project"\app`})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, f := range files {
+				if !strings.HasSuffix(f.path, ".go") {
+					continue
+				}
+				_, err := parser.ParseFile(token.NewFileSet(), f.path, f.content, 0)
+				if err != nil {
+					t.Errorf("invalid Go code in %s: %s", f.path, err)
+				}
+			}
+		})
+	}
+
+	if err := validateInitName("project"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := validateInitName("my\nproject"); err == nil {
+		t.Errorf("expected error for a name with a new line")
+	}
+
+	_, err = initTemplateFS("unknown", "")
+	if err == nil {
+		t.Errorf("expected error for unknown template")
+	}
+}
+
+func TestInitUserTemplates(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "custom", "bakefiles"), 0755)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "custom", "bakefiles", "main.go.gotmpl"), []byte("// {{.Name}}\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "custom", "README.adoc"), []byte("= {{.Name}}\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	names, err := initTemplateNames(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Contains(names, "custom") {
+		t.Errorf("user template not listed: %v", names)
+	}
+
+	fsys, err := initTemplateFS("custom", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	files, err := renderInitTemplate(fsys, map[string]string{"Name": "project"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := map[string]string{}
+	for _, f := range files {
+		got[f.path] = string(f.content)
+	}
+	// Only .gotmpl files are rendered
+	expected := map[string]string{
+		"README.adoc":                         "= {{.Name}}\n",
+		filepath.Join("bakefiles", "main.go"): "// project\n",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("got %v, want %v", got, expected)
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("%s: got %q, want %q", k, got[k], v)
+		}
+	}
+}
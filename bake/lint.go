@@ -0,0 +1,238 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// LintIssue - A problem found in a bakefiles function.
+type LintIssue struct {
+	Pos token.Position
+	Msg string
+}
+
+func (li LintIssue) String() string {
+	file := li.Pos.Filename
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil {
+			file = rel
+		}
+	}
+	return fmt.Sprintf("%s:%d: %s", file, li.Pos.Line, li.Msg)
+}
+
+func LintRun(dir string) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		issues, err := Lint(ctx, dir)
+		if err != nil {
+			return fmt.Errorf("failed to lint: %w", err)
+		}
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("found %d issues", len(issues))
+		}
+		return nil
+	}
+}
+
+// Regex for a description that looks like it tries to declare a command name but doesn't match descriptionRe:
+//
+//	say:hello- description
+//	say:hello  - description
+//	say:hello – description
+//	say:hello description
+var malformedDescriptionRe = regexp.MustCompile(`^\w\S*(-\s|\s+[-–—])|^\w\S*:\S+\s`)
+
+// nearMissTask - Reports whether a function that doesn't match the task signature looks like it is meant to be a task.
+// Either it returns a getoptions.CommandFn, or it has a task description and takes the opt parameter without returning anything.
+// Helpers that take the opt parameter and return something else are not tasks.
+func nearMissTask(fnDecl FnDecl) bool {
+	x := fnDecl.Node.(*ast.FuncDecl)
+	if x.Type.Results != nil && len(x.Type.Results.List) > 0 {
+		for _, result := range x.Type.Results.List {
+			if strings.Contains(exprString(fnDecl, result.Type), "CommandFn") {
+				return true
+			}
+		}
+		return false
+	}
+	return len(x.Type.Params.List) == 1 &&
+		strings.Contains(exprString(fnDecl, x.Type.Params.List[0].Type), "getoptions.GetOpt") &&
+		descriptionRe.MatchString(strings.TrimSpace(fnDecl.Description))
+}
+
+// Lint - Reports functions that almost match the bake task signature and other mistakes bake would silently ignore.
+func Lint(ctx context.Context, dir string) ([]LintIssue, error) {
+	issues := []LintIssue{}
+	ot := NewOptTree(getoptions.New())
+	declared := map[string]token.Position{}
+	depends := map[string][]string{}
+	dependsPos := map[string]token.Position{}
+
+	for p, err := range parsedFiles(dir) {
+		if err != nil {
+			return issues, err
+		}
+		for _, decl := range p.f.Decls {
+			x, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			fnDecl := FnDecl{
+				Name:        x.Name.Name,
+				Description: x.Doc.Text(),
+				Node:        x,
+				ParsedFile:  p,
+				Type:        exprString(FnDecl{ParsedFile: p}, x.Type),
+			}
+			pos := p.fset.Position(x.Pos())
+			report := func(format string, a ...any) {
+				issues = append(issues, LintIssue{Pos: pos, Msg: fmt.Sprintf("%s: %s", fnDecl.Name, fmt.Sprintf(format, a...))})
+			}
+
+			optFieldName, err := taskSignature(fnDecl)
+			if err != nil {
+				// Only report functions that look like they are meant to be tasks
+				if nearMissTask(fnDecl) {
+					report("%s", err)
+				}
+				continue
+			}
+			getOptFn := GetOptFn{FnDecl: fnDecl, OptFieldName: optFieldName}
+
+			getOptFn.Directives, err = parseDirectives(fnDecl)
+			if err != nil {
+				report("%s", strings.TrimPrefix(err.Error(), fnDecl.Name+": "))
+			}
+
+			description := strings.TrimSpace(fnDecl.Description)
+			getOptFn.DescName, getOptFn.Description = parseDescription(fnDecl.Name, description)
+			if description != "" && !descriptionRe.MatchString(description) && malformedDescriptionRe.MatchString(description) {
+				report("malformed description prefix, expected '<name>[:<sub>] - <description>', using name '%s'", getOptFn.DescName)
+			}
+
+			issues = append(issues, lintOptionsInClosure(getOptFn)...)
+
+			// Loading tolerates duplicates, only lint reports them
+			if first, ok := declared[getOptFn.DescName]; ok {
+				report("duplicate command path '%s', first declared at %s:%d", getOptFn.DescName, filepath.Base(first.Filename), first.Line)
+				continue
+			}
+			_, err = ot.AddCommand(getOptFn.Name, getOptFn.DescName, getOptFn.Description, getOptFn.Directives)
+			if err != nil {
+				report("%s", err)
+				continue
+			}
+			declared[getOptFn.DescName] = pos
+			depends[getOptFn.DescName] = getOptFn.Directives.Depends
+			dependsPos[getOptFn.DescName] = pos
+		}
+	}
+
+	// Dependencies can only be checked once all tasks are known
+	for id, deps := range depends {
+		for _, dep := range deps {
+			if _, ok := declared[dep]; !ok {
+				issues = append(issues, LintIssue{Pos: dependsPos[id], Msg: fmt.Sprintf("%s: bake:depends references unknown task '%s'", id, dep)})
+			}
+		}
+	}
+
+	slices.SortStableFunc(issues, func(a, b LintIssue) int {
+		if c := strings.Compare(a.Pos.Filename, b.Pos.Filename); c != 0 {
+			return c
+		}
+		return a.Pos.Line - b.Pos.Line
+	})
+	return issues, nil
+}
+
+// Options bake reads from the task definition
+var optionMethods = map[string]struct{}{
+	"Bool": {}, "BoolVar": {},
+	"String": {}, "StringVar": {}, "StringOptional": {}, "StringVarOptional": {},
+	"Int": {}, "IntVar": {}, "IntOptional": {}, "IntVarOptional": {},
+	"Increment": {}, "IncrementVar": {},
+	"Float64": {}, "Float64Var": {}, "Float64Optional": {}, "Float64VarOptional": {},
+	"StringSlice": {}, "StringSliceVar": {}, "IntSlice": {}, "IntSliceVar": {},
+	"Float64Slice": {}, "Float64SliceVar": {}, "StringMap": {}, "StringMapVar": {},
+}
+
+// lintOptionsInClosure - Reports options declared after the return statement, inside the returned function.
+// Bake only sees the options declared before the return statement.
+func lintOptionsInClosure(getOptFn GetOptFn) []LintIssue {
+	issues := []LintIssue{}
+	x := getOptFn.Node.(*ast.FuncDecl)
+	if x.Body == nil {
+		return issues
+	}
+	for _, stmt := range x.Body.List {
+		ret, ok := stmt.(*ast.ReturnStmt)
+		if !ok {
+			continue
+		}
+		for _, result := range ret.Results {
+			fnLit, ok := result.(*ast.FuncLit)
+			if !ok {
+				continue
+			}
+			names := []string{getOptFn.OptFieldName}
+			for _, param := range fnLit.Type.Params.List {
+				if exprString(getOptFn.FnDecl, param.Type) == "*getoptions.GetOpt" {
+					for _, n := range param.Names {
+						names = append(names, n.Name)
+					}
+				}
+			}
+			ast.Inspect(fnLit.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				fun, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				xIdent, ok := fun.X.(*ast.Ident)
+				if !ok || !slices.Contains(names, xIdent.Name) {
+					return true
+				}
+				if _, ok := optionMethods[fun.Sel.Name]; !ok {
+					return true
+				}
+				name := ""
+				if len(call.Args) > 0 {
+					name = exprString(getOptFn.FnDecl, call.Args[0])
+					if strings.Contains(fun.Sel.Name, "Var") && len(call.Args) > 1 {
+						name = exprString(getOptFn.FnDecl, call.Args[1])
+					}
+				}
+				issues = append(issues, LintIssue{
+					Pos: getOptFn.ParsedFile.fset.Position(call.Pos()),
+					Msg: fmt.Sprintf("%s: option %s declared inside the returned function, bake can't see it, declare it before the return statement", getOptFn.Name, name),
+				})
+				return true
+			})
+		}
+	}
+	return issues
+}
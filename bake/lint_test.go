@@ -0,0 +1,147 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lintSrc = `package main
+
+import (
+	"context"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// say:hello - Valid task
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn {
+	opt.String("lang", "en")
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		opt.Bool("late", false)
+		return nil
+	}
+}
+
+// say:bye - Wrong param type
+func Bye(opt getoptions.GetOpt) getoptions.CommandFn {
+	return nil
+}
+
+// say:hi - Missing return
+func Hi(opt *getoptions.GetOpt) {
+}
+
+// say:hola- Malformed prefix
+func Hola(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return nil
+}
+
+// build:go - Keyword
+func Go(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return nil
+}
+
+// say:hello - Duplicate
+//
+//bake:depends unknown
+func Hello2(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return nil
+}
+
+// unexported - Not exported
+func unexported(opt *getoptions.GetOpt) getoptions.CommandFn {
+	return nil
+}
+
+// Helper is not a task and is not reported
+func Helper(s string) error {
+	return nil
+}
+
+// AddCommon - Helpers taking the opt parameter are not reported
+func AddCommon(opt *getoptions.GetOpt) error {
+	return nil
+}
+
+// notused - Functions with a task description but no task shape are not reported
+func NotUsed() error {
+	return nil
+}
+
+// noopt - Valid task without options
+func NoOpt(_ *getoptions.GetOpt) getoptions.CommandFn {
+	return nil
+}
+`
+
+func TestLint(t *testing.T) {
+	// The fixture is its own module, keep a parent go.work from interfering
+	t.Setenv("GOWORK", "off")
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module bake\n\ngo 1.23\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "main.go"), []byte(lintSrc), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	issues, err := Lint(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := []string{}
+	for _, issue := range issues {
+		got = append(got, filepath.Base(issue.Pos.Filename)+":"+issue.String()[strings.Index(issue.String(), ":")+1:])
+	}
+	expected := []string{
+		"main.go:13: Hello: option \"late\" declared inside the returned function, bake can't see it, declare it before the return statement",
+		"main.go:19: Bye: parameter type is 'getoptions.GetOpt', expected '*getoptions.GetOpt'",
+		"main.go:24: Hi: missing return type 'getoptions.CommandFn'",
+		"main.go:28: Hola: malformed description prefix, expected '<name>[:<sub>] - <description>', using name 'hola'",
+		"main.go:33: Go: command name 'go' in 'build:go' is a golang keyword",
+		"main.go:40: Hello2: duplicate command path 'say:hello', first declared at main.go:10",
+		"main.go:45: unexported: function is not exported",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("got %d issues, want %d:\n%s", len(got), len(expected), strings.Join(got, "\n"))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("got:\n%s\nwant:\n%s", got[i], expected[i])
+		}
+	}
+}
+
+// The bundled examples document valid usage, they must lint clean.
+func TestLintExamples(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	dirs, err := filepath.Glob("examples/*/bakefiles")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(dirs) == 0 {
+		t.Fatalf("no examples found")
+	}
+	for _, dir := range dirs {
+		issues, err := Lint(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%s: %s", dir, issue)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// TaskInfo - Task description used for the machine readable task listing.
+type TaskInfo struct {
+	ID           string   `json:"id"`
+	Command      []string `json:"command"`
+	Function     string   `json:"function"`
+	Description  string   `json:"description"`
+	Timeout      string   `json:"timeout,omitempty"`
+	Options      []Option `json:"options"`
+	Dependencies []string `json:"dependencies"` // bake:depends tasks, run before it by bake run
+	EnvFiles     []string `json:"env_files,omitempty"`
+}
+
+func (ot *OptTree) TaskInfos() []TaskInfo {
+	infos := []TaskInfo{}
+	for _, node := range ot.Tasks() {
+		info := TaskInfo{
+			ID:           node.FullName,
+			Command:      strings.Split(node.FullName, ":"),
+			Function:     node.Name,
+			Description:  node.Description,
+			Timeout:      node.Directives.Timeout,
+			Options:      node.Options,
+			Dependencies: node.Directives.Depends,
+			EnvFiles:     node.Directives.Env,
+		}
+		if info.Options == nil {
+			info.Options = []Option{}
+		}
+		if info.Dependencies == nil {
+			info.Dependencies = []string{}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func ListRun(ot *OptTree) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		if opt.Value("json").(bool) {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err := enc.Encode(ot.TaskInfos())
+			if err != nil {
+				return fmt.Errorf("failed to encode task list: %w", err)
+			}
+			return nil
+		}
+		return ot.WriteTaskList(os.Stdout)
+	}
+}
+
+// WriteTaskList - Writes the tasks with their descriptions.
+// The bake:depends tasks are listed as bake run ordering.
+func (ot *OptTree) WriteTaskList(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, info := range ot.TaskInfos() {
+		if len(info.Dependencies) > 0 {
+			fmt.Fprintf(tw, "%s\t%s\t(bake run after: %s)\n", info.ID, info.Description, strings.Join(info.Dependencies, ", "))
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", info.ID, info.Description)
+	}
+	return tw.Flush()
+}
+
+func GraphRun(ot *OptTree) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+		switch opt.Value("format").(string) {
+		case "mermaid":
+			ot.WriteMermaidGraph(os.Stdout)
+		default:
+			ot.WriteDotGraph(os.Stdout)
+		}
+		return nil
+	}
+}
+
+// WriteDotGraph - Writes the bake run ordering declared with bake:depends as a dot diagram.
+// Edges go from a task to the tasks bake run runs before it.
+// Dependencies wired in Go with the task map are not known to bake and are not included.
+func (ot *OptTree) WriteDotGraph(w io.Writer) {
+	fmt.Fprintf(w, "digraph G {\n\tlabel = \"bake run ordering (bake:depends)\";\n\trankdir = TB;\n")
+	for _, info := range ot.TaskInfos() {
+		fmt.Fprintf(w, "\t\"%s\";\n", info.ID)
+		for _, dep := range info.Dependencies {
+			fmt.Fprintf(w, "\t\"%s\" -> \"%s\";\n", info.ID, dep)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// WriteMermaidGraph - Writes the bake run ordering declared with bake:depends as a mermaid flowchart.
+// Edges go from a task to the tasks bake run runs before it.
+func (ot *OptTree) WriteMermaidGraph(w io.Writer) {
+	ids := map[string]string{}
+	nodeID := func(task string) string {
+		id, ok := ids[task]
+		if !ok {
+			id = fmt.Sprintf("t%d", len(ids))
+			ids[task] = id
+		}
+		return id
+	}
+	fmt.Fprintf(w, "---\ntitle: bake run ordering (bake:depends)\n---\nflowchart TB\n")
+	for _, info := range ot.TaskInfos() {
+		fmt.Fprintf(w, "\t%s[\"%s\"]\n", nodeID(info.ID), info.ID)
+	}
+	for _, info := range ot.TaskInfos() {
+		for _, dep := range info.Dependencies {
+			_, known := ids[dep]
+			if !known {
+				fmt.Fprintf(w, "\t%s[\"%s\"]\n", nodeID(dep), dep)
+			}
+			fmt.Fprintf(w, "\t%s --> %s\n", nodeID(info.ID), nodeID(dep))
+		}
+	}
+}
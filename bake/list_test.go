@@ -0,0 +1,99 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func testOptTree(t *testing.T) *OptTree {
+	t.Helper()
+	ot := NewOptTree(getoptions.New())
+	_, err := ot.AddCommand("Binary", "build:binary", "Builds the binary", Directives{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = ot.AddCommand("Deploy", "deploy", "Deploys", Directives{Depends: []string{"build:binary", "test"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return ot
+}
+
+func TestTaskInfos(t *testing.T) {
+	ot := testOptTree(t)
+	infos := ot.TaskInfos()
+	if len(infos) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(infos))
+	}
+	if infos[0].ID != "build:binary" || infos[1].ID != "deploy" {
+		t.Errorf("unexpected order: %s, %s", infos[0].ID, infos[1].ID)
+	}
+	if len(infos[0].Command) != 2 || infos[0].Command[0] != "build" || infos[0].Command[1] != "binary" {
+		t.Errorf("unexpected command: %v", infos[0].Command)
+	}
+	if infos[0].Dependencies == nil || infos[0].Options == nil {
+		t.Errorf("expected empty lists, got nil")
+	}
+}
+
+func TestWriteTaskList(t *testing.T) {
+	ot := testOptTree(t)
+	var buf bytes.Buffer
+	err := ot.WriteTaskList(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := `build:binary  Builds the binary
+deploy        Deploys  (bake run after: build:binary, test)
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestWriteDotGraph(t *testing.T) {
+	ot := testOptTree(t)
+	var buf bytes.Buffer
+	ot.WriteDotGraph(&buf)
+	expected := `digraph G {
+	label = "bake run ordering (bake:depends)";
+	rankdir = TB;
+	"build:binary";
+	"deploy";
+	"deploy" -> "build:binary";
+	"deploy" -> "test";
+}
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestWriteMermaidGraph(t *testing.T) {
+	ot := testOptTree(t)
+	var buf bytes.Buffer
+	ot.WriteMermaidGraph(&buf)
+	expected := `---
+title: bake run ordering (bake:depends)
+---
+flowchart TB
+	t0["build:binary"]
+	t1["deploy"]
+	t1 --> t0
+	t2["test"]
+	t1 --> t2
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
@@ -58,6 +59,8 @@ func program(args []string) int {
 	opt.Self("bake", "Go Build + Something like Make = Bake ¯\\_(ツ)_/¯")
 	opt.SetUnknownMode(getoptions.Pass)
 	opt.Bool("quiet", false, opt.GetEnv("QUIET"))
+	opt.String("timeout", "", opt.ArgName("duration"), opt.GetEnv("BAKE_TIMEOUT"),
+		opt.Description("cancel the task after the given duration, e.g. 30s, 10m, 1h. Overrides the task default timeout."))
 
 	dir, err := findBakeDir(ctx)
 	if err != nil && !errors.Is(err, ErrNotFound) {
@@ -73,8 +76,16 @@ func program(args []string) int {
 		requiredFilesPresent = bakeDirHasRequiredFiles(dir)
 	}
 
-	if requiredFilesPresent {
-		ot, err := LoadAst(ctx, opt, dir)
+	ot := NewOptTree(opt)
+	if requiredFilesPresent && os.Getenv("COMP_LINE") != "" {
+		// Completion only needs the tree, use the cache and skip the build
+		ot, err = LoadAstCached(ctx, opt, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			return 1
+		}
+	} else if requiredFilesPresent {
+		ot, err = LoadAst(ctx, opt, dir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 			return 1
@@ -84,26 +95,71 @@ func program(args []string) int {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 			return 1
 		}
-		err = buildBinary(dir)
+		err = buildBinary(dir, ot.ImportDirs)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 			return 1
 		}
+		// Saved after the build since it can modify the sources the cache is checked against
+		if err := treeCacheStale(dir, ot.ImportDirs); errors.Is(err, errStaleCache) {
+			err = saveTreeCache(ot, dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+				return 1
+			}
+		}
 	} else {
 		Logger.Printf("Required files not present\n")
 	}
 
+	taskIDs := []string{}
+	for _, node := range ot.Tasks() {
+		taskIDs = append(taskIDs, node.FullName)
+	}
+
+	if requiredFilesPresent && !ot.HasRunCommand() {
+		brun := opt.NewCommand("run", "run the given tasks and their bake:depends tasks in parallel")
+		brun.Int("parallel", runtime.NumCPU(), brun.ArgName("n"), brun.Description("max number of tasks to run in parallel"))
+		brun.CustomCompletion(taskIDs...)
+		brun.SetCommandFn(ot.RunBinary)
+	}
+
 	b := opt.NewCommand("_bake", "")
 
 	bld := b.NewCommand("list-fns", "lists all functions in the package")
 	bld.SetCommandFn(PrintFuncDeclRun(dir))
 
+	blist := b.NewCommand("list", "lists all tasks")
+	blist.Bool("json", false, blist.Description("print the tasks, their options and bake:depends tasks in JSON format"))
+	blist.SetCommandFn(ListRun(ot))
+
+	bgraph := b.NewCommand("graph", "print the bake run ordering declared with bake:depends")
+	bgraph.String("format", "dot", bgraph.ValidValues("dot", "mermaid"))
+	bgraph.SetCommandFn(GraphRun(ot))
+
+	benv := b.NewCommand("env", "print the variables the env files add to the environment of the given task, or the global ones if no task is given")
+	benv.HelpSynopsisArg("<task>", "task ID, e.g. build:binary")
+	benv.CustomCompletion(taskIDs...)
+	benv.SetCommandFn(EnvRun(ot))
+
+	blint := b.NewCommand("lint", "report functions that almost match the task signature and other task declaration mistakes")
+	blint.SetCommandFn(LintRun(dir))
+
 	binit := b.NewCommand("init", "initialize a new bake project")
+	binit.String("template", "", binit.ArgName("name"), binit.SuggestedValues(embeddedInitTemplateNames()...),
+		binit.Description("project template with example tasks"))
+	binit.String("template-dir", "", binit.ArgName("dir"), binit.GetEnv("BAKE_TEMPLATE_DIR"),
+		binit.Description("dir with user templates, each template is a sub dir with the files to create relative to the project root"))
+	binit.Bool("list-templates", false, binit.Description("list the available templates"))
+	binit.Bool("dry-run", false, binit.Description("list the files that would be created and the commands that would run"))
 	binit.SetCommandFn(initRun(dir))
 
 	bforce := b.NewCommand("force", "force rebuild of the generated bake file and the binary on the next run")
 	bforce.SetCommandFn(InvalidateCache(dir))
 
+	bcache := b.NewCommand("invalidate-cache", "remove the cached task tree used for completion")
+	bcache.SetCommandFn(InvalidateTreeCache(dir))
+
 	bversion := b.NewCommand("version", "print the version of bake")
 	bversion.SetCommandFn(Version(dir))
 
@@ -119,6 +175,11 @@ func program(args []string) int {
 		if errors.Is(err, getoptions.ErrorHelpCalled) {
 			return 1
 		}
+		// Pass through the exit code of the task binary
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode()
+		}
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		return 1
 	}
@@ -10,9 +10,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/DavidGamba/dgtools/run"
@@ -23,8 +27,12 @@ import (
 // type TaskFn func(*getoptions.GetOpt) getoptions.CommandFn
 
 type OptTree struct {
-	Root    *OptNode
-	fnsList map[string]struct{}
+	Root       *OptNode
+	fnsList    map[string]struct{}
+	reserved   map[string]struct{} // names imported task packages can't use as their alias
+	Imports    map[string]string   // import path to package alias
+	ImportDirs []string            // source dirs of the imported task packages
+	EnvFiles   []string            // env files loaded for every task
 }
 
 type OptNode struct {
@@ -36,6 +44,24 @@ type OptNode struct {
 	Description string
 	OptFnName   string
 	FullName    string
+	Directives  Directives
+	Options     []Option
+}
+
+// Option - Describes an option declared by a task.
+type Option struct {
+	Name            string   `json:"name"`
+	Type            string   `json:"type"`
+	Default         string   `json:"default"`
+	Aliases         []string `json:"aliases,omitempty"`
+	ArgName         string   `json:"arg_name,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Env             string   `json:"env,omitempty"`
+	Required        bool     `json:"required,omitempty"`
+	ValidValues     []string `json:"valid_values,omitempty"`
+	SuggestedValues []string `json:"suggested_values,omitempty"`
+
+	method string // getoptions method used to declare the option
 }
 
 func NewOptTree(opt *getoptions.GetOpt) *OptTree {
@@ -50,14 +76,87 @@ func NewOptTree(opt *getoptions.GetOpt) *OptTree {
 			OptFnName:   "",
 			FullName:    "",
 		},
-		fnsList: make(map[string]struct{}),
+		fnsList:  make(map[string]struct{}),
+		reserved: make(map[string]struct{}),
+		Imports:  make(map[string]string),
+	}
+}
+
+// AddImport - Registers an imported task package and returns the alias used to refer to it.
+func (ot *OptTree) AddImport(path, name string) string {
+	if alias, ok := ot.Imports[path]; ok {
+		return alias
+	}
+	alias := name
+	for i := 2; ot.aliasInUse(alias); i++ {
+		alias = fmt.Sprintf("%s%d", name, i)
+	}
+	ot.Imports[path] = alias
+	return alias
+}
+
+// ReserveNames - Registers names declared by the generated main file and the bakefiles.
+// Imported task packages can't use them as their alias.
+func (ot *OptTree) ReserveNames(names ...string) {
+	for _, name := range names {
+		ot.reserved[name] = struct{}{}
+	}
+}
+
+func (ot *OptTree) aliasInUse(alias string) bool {
+	if _, ok := ot.reserved[alias]; ok {
+		return true
+	}
+	for _, a := range ot.Imports {
+		if a == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportsString - Returns the import lines for the imported task packages.
+func (ot *OptTree) ImportsString() string {
+	paths := slices.Sorted(maps.Keys(ot.Imports))
+	out := ""
+	for _, path := range paths {
+		out += fmt.Sprintf("%s \"%s\"\n", ot.Imports[path], path)
+	}
+	return out
+}
+
+// RunBinary - Runs the compiled bake binary with the original input args.
+// The env file variables are passed to the binary in the BAKE_ENV env var.
+func (ot *OptTree) RunBinary(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+	Logger.Printf("Running %v from %s\n", InputArgs, Dir)
+	env, err := ot.BakeEnv()
+	if err != nil {
+		return err
 	}
+	// filepath.Join removes the ./ if Dir is .
+	// Need to ensure that it is running the local binary, not the one in the PATH
+	cmd := "./bake"
+	if Dir != "." {
+		cmd = filepath.Join(Dir, "bake")
+	}
+	c := []string{cmd}
+	// The task binary prints its own errors, only pass its exit code through
+	err = run.CMD(append(c, InputArgs...)...).Env("BAKE_ENV=" + env).Log().Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr
+	}
+	return err
 }
 
+// ErrDuplicateCommand - Returned by AddCommand when the command path is already declared by another function.
+// The loader ignores the second declaration, bake lint reports it.
+var ErrDuplicateCommand = errors.New("duplicate command")
+
 // Regex for description: fn-name - description
-var descriptionRe = regexp.MustCompile(`^\w\S+ -`)
+var descriptionRe = regexp.MustCompile(`^\w\S* -`)
 
-func (ot *OptTree) AddCommand(name, descName, description string) (*getoptions.GetOpt, error) {
+func (ot *OptTree) AddCommand(name, descName, description string, directives Directives) (*getoptions.GetOpt, error) {
 	Logger.Printf("Adding command %s with function %s\n", descName, name)
 	keys := strings.Split(descName, ":")
 	node := ot.Root
@@ -66,13 +165,20 @@ func (ot *OptTree) AddCommand(name, descName, description string) (*getoptions.G
 		keyCamel := kebabToCamel(key)
 
 		// Check if already defined
-		n, ok := node.Children[keyCamel]
+		n, ok := node.Children[key]
 		if ok {
-			Logger.Printf("key: %v already defined, parent: %s\n", keyCamel, node.DescName)
+			Logger.Printf("key: %v already defined, parent: %s\n", key, node.DescName)
 			node = n
 			cmd = n.Opt
 			if len(keys) == i+1 {
+				if n.Name != "" {
+					return nil, fmt.Errorf("%w: '%s' in %s and %s", ErrDuplicateCommand, descName, n.Name, name)
+				}
+				n.Name = name
 				cmd.Self(key, description)
+				n.Description = description
+				n.Directives = directives
+				cmd.SetCommandFn(ot.RunBinary)
 			}
 			continue
 		}
@@ -87,6 +193,10 @@ func (ot *OptTree) AddCommand(name, descName, description string) (*getoptions.G
 		if err != nil {
 			return nil, err
 		}
+		// Ensure the name doesn't collide with a bake builtin command
+		if _, ok := reservedCmdNames[key]; ok && i == 0 {
+			return nil, fmt.Errorf("command name '%s' in '%s' is reserved by bake", key, descName)
+		}
 
 		// Ensure the name is unique
 		optFnName := keyCamel
@@ -100,30 +210,20 @@ func (ot *OptTree) AddCommand(name, descName, description string) (*getoptions.G
 		cmd = node.Opt.NewCommand(key, desc)
 		node.Children[key] = &OptNode{
 			Name:        "",
-			Parent:      node.DescName,
+			Parent:      node.OptFnName,
 			Opt:         cmd,
 			Children:    make(map[string]*OptNode),
 			Description: desc,
 			DescName:    key,
 			OptFnName:   optFnName,
-			FullName:    descName,
+			FullName:    strings.Join(keys[:i+1], ":"),
 		}
 
 		// Set the command function
 		if len(keys) == i+1 {
 			node.Children[key].Name = name
-			cmd.SetCommandFn(func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
-				Logger.Printf("Running %v from %s\n", InputArgs, Dir)
-				// filepath.Join removes the ./ if Dir is .
-				// Need to ensure that it is running the local binary, not the one in the PATH
-				cmd := "./bake"
-				if Dir != "." {
-					cmd = filepath.Join(Dir, "bake")
-				}
-				c := []string{cmd}
-				run.CMD(append(c, InputArgs...)...).Log().Run()
-				return nil
-			})
+			node.Children[key].Directives = directives
+			cmd.SetCommandFn(ot.RunBinary)
 		}
 
 		// Get ready for the next iteration
@@ -132,6 +232,38 @@ func (ot *OptTree) AddCommand(name, descName, description string) (*getoptions.G
 	return cmd, nil
 }
 
+// Node - Returns the node for the given task ID (e.g. say:hello) or nil if not found.
+func (ot *OptTree) Node(descName string) *OptNode {
+	node := ot.Root
+	for _, key := range strings.Split(descName, ":") {
+		n, ok := node.Children[key]
+		if !ok {
+			return nil
+		}
+		node = n
+	}
+	return node
+}
+
+// Tasks - Returns all the nodes that have a task function sorted by task ID.
+func (ot *OptTree) Tasks() []*OptNode {
+	tasks := []*OptNode{}
+	var walk func(on *OptNode)
+	walk = func(on *OptNode) {
+		if on.Name != "" {
+			tasks = append(tasks, on)
+		}
+		for _, child := range on.Children {
+			walk(child)
+		}
+	}
+	walk(ot.Root)
+	slices.SortFunc(tasks, func(a, b *OptNode) int {
+		return strings.Compare(a.FullName, b.FullName)
+	})
+	return tasks
+}
+
 var golangKeywords = map[string]struct{}{
 	"break":       {},
 	"default":     {},
@@ -160,6 +292,18 @@ var golangKeywords = map[string]struct{}{
 	"var":         {},
 }
 
+// Top level commands added by bake
+var reservedCmdNames = map[string]struct{}{
+	"_bake": {},
+}
+
+// HasRunCommand - Indicates if the bakefiles declare a top level run command.
+// The built-in parallel runner is only added when they don't, so existing run tasks keep working.
+func (ot *OptTree) HasRunCommand() bool {
+	_, ok := ot.Root.Children["run"]
+	return ok
+}
+
 func validateCmdName(name, descName string) error {
 	// if command name matches a golang keyword, return an error
 	if _, ok := golangKeywords[name]; ok {
@@ -184,9 +328,20 @@ func (on *OptNode) String() string {
 	}
 
 	if on.Name != "" {
-		out += fmt.Sprintf("%sFn := %s(%s)\n", on.OptFnName, on.Name, on.OptFnName)
+		fn := fmt.Sprintf("%s(%s)", on.Name, on.OptFnName)
+		if on.Directives.Timeout != "" {
+			fn = fmt.Sprintf("taskTimeout(%s, \"%s\")", fn, on.Directives.Timeout)
+		}
+		if len(on.Directives.Env) > 0 {
+			fn = fmt.Sprintf("taskEnv(%s, \"%s\")", fn, on.FullName)
+		}
+		out += fmt.Sprintf("%sFn := %s\n", on.OptFnName, fn)
 		out += fmt.Sprintf("%s.SetCommandFn(%sFn)\n", on.OptFnName, on.OptFnName)
-		out += fmt.Sprintf("TM.Add(\"%s\", %sFn)\n\n", on.FullName, on.OptFnName)
+		out += fmt.Sprintf("TM.Add(\"%s\", %sFn)\n", on.FullName, on.OptFnName)
+		if len(on.Directives.Depends) > 0 {
+			out += fmt.Sprintf("TaskDeps[\"%s\"] = %#v\n", on.FullName, on.Directives.Depends)
+		}
+		out += "\n"
 	}
 	for _, child := range on.Children {
 		out += child.String()
@@ -0,0 +1,200 @@
+// This file is part of bake.
+//
+// Copyright (C) 2023-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func TestOptTreeString(t *testing.T) {
+	ot := NewOptTree(getoptions.New())
+	ot.EnvFiles = []string{"build.env"}
+	_, err := ot.AddCommand("Deploy", "deploy", "Deploys", Directives{Timeout: "5m", Depends: []string{"build:binary", "test"}, Env: []string{"deploy.env"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := ot.String()
+	expected := "deploy := opt.NewCommand(\"deploy\", `Deploys`)\n" +
+		"deployFn := taskEnv(taskTimeout(Deploy(deploy), \"5m\"), \"deploy\")\n" +
+		"deploy.SetCommandFn(deployFn)\n" +
+		"TM.Add(\"deploy\", deployFn)\n" +
+		"TaskDeps[\"deploy\"] = []string{\"build:binary\", \"test\"}\n\n"
+	if got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestAddCommandReservedName(t *testing.T) {
+	ot := NewOptTree(getoptions.New())
+	_, err := ot.AddCommand("Bake", "_bake", "", Directives{})
+	if err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("expected reserved name error, got %v", err)
+	}
+	_, err = ot.AddCommand("Run", "tests:run", "", Directives{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if ot.HasRunCommand() {
+		t.Errorf("unexpected run command")
+	}
+	// A user run task replaces the built-in parallel runner
+	_, err = ot.AddCommand("Run", "run", "", Directives{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !ot.HasRunCommand() {
+		t.Errorf("expected run command")
+	}
+}
+
+func TestAddCommandDuplicate(t *testing.T) {
+	ot := NewOptTree(getoptions.New())
+	_, err := ot.AddCommand("Hello", "say:hello", "First", Directives{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = ot.AddCommand("Hello2", "say:hello", "Second", Directives{Timeout: "1m"})
+	if !errors.Is(err, ErrDuplicateCommand) {
+		t.Errorf("got %v, want ErrDuplicateCommand", err)
+	}
+	node := ot.Node("say:hello")
+	if node.Name != "Hello" || node.Description != "First" || node.Directives.Timeout != "" {
+		t.Errorf("got %s %s %s, want the first declaration", node.Name, node.Description, node.Directives.Timeout)
+	}
+}
+
+// The loader ignores the second declaration of a command path, including its options.
+func TestLoadAstDuplicate(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module bake\n\ngo 1.23\n\nrequire github.com/DavidGamba/go-getoptions v0.30.0\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "go.sum"), []byte(getoptionsGoSum(t, "go.sum")), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	"context"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// say:hello - First
+func Hello(opt *getoptions.GetOpt) getoptions.CommandFn {
+	opt.String("name", "")
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error { return nil }
+}
+
+// say:hello - Second
+func Hello2(opt *getoptions.GetOpt) getoptions.CommandFn {
+	opt.Bool("loud", false)
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error { return nil }
+}
+
+// say:bye - Task without options
+func Bye(_ *getoptions.GetOpt) getoptions.CommandFn {
+	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error { return nil }
+}
+`), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ot, err := LoadAst(context.Background(), getoptions.New(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	node := ot.Node("say:hello")
+	if node.Name != "Hello" || node.Description != "First" {
+		t.Errorf("got %s %s, want Hello First", node.Name, node.Description)
+	}
+	if len(node.Options) != 1 || node.Options[0].Name != "name" {
+		t.Errorf("got options %v, want only name", node.Options)
+	}
+	if node := ot.Node("say:bye"); node == nil || node.Name != "Bye" {
+		t.Errorf("task with an unnamed opt parameter not loaded")
+	}
+}
+
+// Children are stored by their kebab case key, so the second task of a kebab case parent reuses it.
+func TestAddCommandKebabParent(t *testing.T) {
+	ot := NewOptTree(getoptions.New())
+	for _, name := range []string{"say-hi:a", "say-hi:b"} {
+		_, err := ot.AddCommand("Fn", name, "", Directives{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if len(ot.Root.Children) != 1 || len(ot.Root.Children["say-hi"].Children) != 2 {
+		t.Errorf("got %d top level commands, want a single say-hi command with 2 children", len(ot.Root.Children))
+	}
+	if got := strings.Count(ot.String(), ".NewCommand(\"say-hi\""); got != 1 {
+		t.Errorf("got say-hi declared %d times, want 1", got)
+	}
+}
+
+// The generated code creates the child from the parent variable, the kebab case name is not a valid identifier.
+func TestAddCommandParentVariable(t *testing.T) {
+	ot := NewOptTree(getoptions.New())
+	_, err := ot.AddCommand("Fn", "say-hi:a", "", Directives{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := ot.String()
+	if !strings.Contains(got, "a := sayHi.NewCommand(\"a\", ``)\n") {
+		t.Errorf("child not created from the parent variable:\n%s", got)
+	}
+}
+
+// Intermediate commands get their own task ID, not the ID of the task that created them.
+func TestAddCommandIntermediateTask(t *testing.T) {
+	ot := NewOptTree(getoptions.New())
+	_, err := ot.AddCommand("Hello", "say:hello", "Says hello", Directives{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = ot.AddCommand("Say", "say", "Says", Directives{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	node := ot.Node("say")
+	if node.FullName != "say" || node.Name != "Say" || node.Description != "Says" {
+		t.Errorf("got %s %s %s, want say Say Says", node.FullName, node.Name, node.Description)
+	}
+	if !strings.Contains(ot.String(), "TM.Add(\"say\", sayFn)\n") {
+		t.Errorf("intermediate task not added:\n%s", ot.String())
+	}
+}
+
+func TestParseDescription(t *testing.T) {
+	tests := []struct {
+		fn, description, name, want string
+	}{
+		{"Hello", "say:hello - Says hello", "say:hello", "Says hello"},
+		{"A", "a - Single letter name", "a", "Single letter name"},
+		{"Hello", "Says hello", "hello", "Says hello"},
+	}
+	for _, tt := range tests {
+		name, description := parseDescription(tt.fn, tt.description)
+		if name != tt.name || description != tt.want {
+			t.Errorf("got %q %q, want %q %q", name, description, tt.name, tt.want)
+		}
+	}
+}
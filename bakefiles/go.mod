@@ -2,6 +2,9 @@ module bake
 
 go 1.22.4
 
+// Env files in the tool dir, e.g. GOEXPERIMENT=rangefunc for bake
+// bake:env build.env
+
 require (
 	github.com/DavidGamba/dgtools/run v0.9.0
 	github.com/DavidGamba/go-getoptions v0.30.0
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/sha256"
@@ -9,7 +8,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 
@@ -18,36 +16,6 @@ import (
 	"golang.org/x/mod/semver"
 )
 
-func getEnv(file string) ([]string, error) {
-	env := []string{}
-	// Check if build.env exists and load it if it does
-	if _, err := os.Stat(file); err == nil {
-
-		commentRegex := regexp.MustCompile(`^\s*#|^\s*//`)
-
-		file, err := os.Open(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open file: %w", err)
-		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if commentRegex.MatchString(line) {
-				continue
-			}
-			Logger.Printf("Adding to env: %s\n", line)
-			env = append(env, line)
-		}
-
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("failed to read file: %w", err)
-		}
-	}
-	return env, nil
-}
-
 // install - Build and install the current binary
 func Install(opt *getoptions.GetOpt) getoptions.CommandFn {
 	return func(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
@@ -57,16 +25,7 @@ func Install(opt *getoptions.GetOpt) getoptions.CommandFn {
 		}
 		Logger.Printf("Running install on %s\n", wd)
 
-		env, err := getEnv("build.env")
-		if err != nil {
-			return fmt.Errorf("failed to get env: %w", err)
-		}
-
-		cmd := run.CMD("go", "install")
-		for _, e := range env {
-			cmd.Env(e)
-		}
-		err = cmd.Log().Run()
+		err = run.CMD("go", "install").Log().Run()
 		if err != nil {
 			return err
 		}
@@ -83,18 +42,9 @@ func Test(opt *getoptions.GetOpt) getoptions.CommandFn {
 		}
 		Logger.Printf("Running test on %s\n", wd)
 
-		env, err := getEnv("build.env")
-		if err != nil {
-			return fmt.Errorf("failed to get env: %w", err)
-		}
-
 		command := []string{"go", "test", "./..."}
 		command = append(command, args...)
-		cmd := run.CMD(command...)
-		for _, e := range env {
-			cmd.Env(e)
-		}
-		err = cmd.Log().Run()
+		err = run.CMD(command...).Log().Run()
 		if err != nil {
 			return err
 		}
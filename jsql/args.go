@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/DavidGamba/dgtools/cueutils"
+	"github.com/DavidGamba/dgtools/run"
+)
+
+// CommandArg - A provider Get command argument, available in the command as $name and $argN.
+type CommandArg struct {
+	Name        string
+	Description string
+	Default     *string  // optional args have a default
+	ValidValues []string `json:"validValues"`
+	Completion  []string // command that prints the valid values, one per line, for shell completion
+
+	CompletionType string `json:"completionType"` // built-in completion instead of a command, like k8s-resources
+}
+
+// Placeholders set by jsql that can't be used as arg names
+var reservedPlaceholders = []string{"table", "schemaName", "provider", "command", "filename"}
+
+// parseArgs - Returns the values of the command args in the order they are defined and by name.
+// Args can be passed as --name value, --name=value or positionally in the order they are defined,
+// optional args that are not passed get their default.
+func parseArgs(defs []CommandArg, args []string) ([]string, map[string]string, error) {
+	named := map[string]string{}
+	for _, def := range defs {
+		if slices.Contains(reservedPlaceholders, def.Name) {
+			return nil, nil, fmt.Errorf("arg name '%s' is reserved", def.Name)
+		}
+	}
+	isDefined := func(name string) bool {
+		return slices.ContainsFunc(defs, func(def CommandArg) bool { return def.Name == name })
+	}
+
+	positional := []string{}
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			positional = append(positional, args[i])
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		if !isDefined(name) {
+			return nil, nil, fmt.Errorf("unknown arg: %s", args[i])
+		}
+		if !ok {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("missing value for arg: %s", args[i])
+			}
+			i++
+			value = args[i]
+		}
+		named[name] = value
+	}
+
+	// Positional args fill the args not passed as flags
+	for _, def := range defs {
+		if _, ok := named[def.Name]; ok || len(positional) == 0 {
+			continue
+		}
+		named[def.Name], positional = positional[0], positional[1:]
+	}
+	if len(positional) > 0 {
+		return nil, nil, fmt.Errorf("too many args: %v", positional)
+	}
+
+	values := []string{}
+	for _, def := range defs {
+		value, ok := named[def.Name]
+		if !ok {
+			if def.Default == nil {
+				return nil, nil, fmt.Errorf("missing arg: %s", def.Name)
+			}
+			value = *def.Default
+			named[def.Name] = value
+		}
+		if len(def.ValidValues) > 0 && !slices.Contains(def.ValidValues, value) {
+			return nil, nil, fmt.Errorf("invalid value for %s: '%s', valid values: %s", def.Name, value, strings.Join(def.ValidValues, ", "))
+		}
+		values = append(values, value)
+	}
+	return values, named, nil
+}
+
+// argPlaceholders - Returns the placeholder values for the args, $name and $argN.
+func argPlaceholders(values []string, named map[string]string) map[string]string {
+	placeholders := map[string]string{}
+	for name, value := range named {
+		placeholders[name] = value
+	}
+	for i, value := range values {
+		placeholders[fmt.Sprintf("arg%d", i+1)] = value
+	}
+	return placeholders
+}
+
+// replacePlaceholders - Replaces each $key in s with its value.
+// Longer keys are matched first so that $arg1 doesn't replace the start of $arg10.
+func replacePlaceholders(s string, placeholders map[string]string) string {
+	keys := make([]string, 0, len(placeholders))
+	for k := range placeholders {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	oldnew := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		oldnew = append(oldnew, "$"+k, placeholders[k])
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
+
+func printArgsUsage(w io.Writer, provider, command string, defs []CommandArg) {
+	fmt.Fprintf(w, "Usage:\n    %s %s", provider, command)
+	for _, arg := range defs {
+		if arg.Default != nil {
+			fmt.Fprintf(w, " [--%s <%s>]", arg.Name, arg.Name)
+		} else {
+			fmt.Fprintf(w, " <%s>", arg.Name)
+		}
+	}
+	fmt.Fprintf(w, "\n\nARGUMENTS:\n")
+	for _, arg := range defs {
+		description := arg.Description
+		if arg.Default != nil {
+			description += fmt.Sprintf(" (default: '%s')", *arg.Default)
+		}
+		if len(arg.ValidValues) > 0 {
+			description += fmt.Sprintf(" (valid values: %s)", strings.Join(arg.ValidValues, ", "))
+		}
+		fmt.Fprintf(w, "    %-20s %s\n", arg.Name, strings.TrimSpace(description))
+	}
+}
+
+// GetCompletions - Completes the provider, the command and the command arg values for the get command.
+// Arg values come from the arg validValues or from the output of the arg completion command.
+func GetCompletions(target string, previousArgs []string, partial string) []string {
+	// Don't print the logs on the user's prompt
+	Logger.SetOutput(io.Discard)
+	cueutils.Logger.SetOutput(io.Discard)
+
+	d := &Config{}
+	err := ReadConfig(d, nil)
+	if err != nil {
+		return nil
+	}
+	candidates := []string{}
+	switch len(previousArgs) {
+	case 0:
+		for name := range d.Provider {
+			candidates = append(candidates, name)
+		}
+	case 1:
+		for name := range d.Provider[previousArgs[0]].GetCommands {
+			candidates = append(candidates, name)
+		}
+	default:
+		provider, command, args := previousArgs[0], previousArgs[1], previousArgs[2:]
+		commandData, ok := d.Provider[provider].GetCommands[command]
+		if !ok {
+			return nil
+		}
+		candidates = argCompletions(commandData.Args, args, map[string]string{
+			"provider":   provider,
+			"schemaName": d.Provider[provider].SchemaName,
+			"command":    command,
+		})
+	}
+	completions := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, partial) {
+			completions = append(completions, c)
+		}
+	}
+	slices.Sort(completions)
+	return completions
+}
+
+// argCompletions - Returns the candidates for the next arg given the args already passed.
+func argCompletions(defs []CommandArg, args []string, placeholders map[string]string) []string {
+	named := map[string]string{}
+	positional := 0
+	var flagDef *CommandArg // arg whose value follows a trailing --name flag
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			positional++
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		if !ok {
+			if i+1 >= len(args) {
+				if j := slices.IndexFunc(defs, func(def CommandArg) bool { return def.Name == name }); j >= 0 {
+					flagDef = &defs[j]
+				}
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		named[name] = value
+	}
+
+	def := flagDef
+	if def == nil {
+		for i := range defs {
+			if _, ok := named[defs[i].Name]; ok {
+				continue
+			}
+			if positional == 0 {
+				def = &defs[i]
+				break
+			}
+			positional--
+		}
+	}
+	if def == nil {
+		return nil
+	}
+	if len(def.ValidValues) > 0 {
+		return def.ValidValues
+	}
+	switch def.CompletionType {
+	case "k8s-resources":
+		names, err := k8sResources()
+		if err != nil {
+			return nil
+		}
+		return names
+	}
+	if len(def.Completion) == 0 {
+		return nil
+	}
+	for name, value := range named {
+		placeholders[name] = value
+	}
+	command := make([]string, len(def.Completion))
+	for i, e := range def.Completion {
+		command[i] = replacePlaceholders(e, placeholders)
+	}
+	out, err := run.CMD(command...).DiscardErr().STDOutOutput()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(out))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	json := "json"
+	defs := []CommandArg{
+		{Name: "resource"},
+		{Name: "namespace", Default: new(string)},
+		{Name: "output", Default: &json, ValidValues: []string{"json", "yaml"}},
+	}
+	tests := []struct {
+		name  string
+		args  []string
+		want  []string
+		named map[string]string
+		err   bool
+	}{
+		{"positional", []string{"pods", "kube-system", "yaml"}, []string{"pods", "kube-system", "yaml"}, map[string]string{"resource": "pods", "namespace": "kube-system", "output": "yaml"}, false},
+		{"defaults", []string{"pods"}, []string{"pods", "", "json"}, map[string]string{"resource": "pods", "namespace": "", "output": "json"}, false},
+		{"flags", []string{"--output", "yaml", "--resource=pods"}, []string{"pods", "", "yaml"}, map[string]string{"resource": "pods", "namespace": "", "output": "yaml"}, false},
+		{"flags and positional", []string{"--resource", "pods", "default"}, []string{"pods", "default", "json"}, map[string]string{"resource": "pods", "namespace": "default", "output": "json"}, false},
+		{"missing", []string{}, nil, nil, true},
+		{"missing flag value", []string{"pods", "--output"}, nil, nil, true},
+		{"unknown flag", []string{"pods", "--bogus", "x"}, nil, nil, true},
+		{"too many", []string{"pods", "default", "json", "extra"}, nil, nil, true},
+		{"invalid value", []string{"pods", "--output", "xml"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, named, err := parseArgs(defs, tt.args)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !maps.Equal(named, tt.named) {
+				t.Errorf("got %v, want %v", named, tt.named)
+			}
+		})
+	}
+
+	_, _, err := parseArgs([]CommandArg{{Name: "table"}}, []string{"pods"})
+	if err == nil {
+		t.Errorf("expected reserved name error, got nil")
+	}
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	placeholders := argPlaceholders([]string{"pods", "default"}, map[string]string{"resource": "pods", "name": "default"})
+	placeholders["filename"] = "/tmp/pods.json"
+	placeholders["arg10"] = "ten"
+	got := replacePlaceholders("$resource $name $filename $arg1 $arg2 $arg10 $other", placeholders)
+	want := "pods default /tmp/pods.json pods default ten $other"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// Values are not expanded again
+	got = replacePlaceholders("$a $b", map[string]string{"a": "$b", "b": "x"})
+	if want := "$b x"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestArgCompletions(t *testing.T) {
+	defs := []CommandArg{
+		{Name: "namespace", Completion: []string{"echo", "$provider-a", "$provider-b"}},
+		{Name: "output", Default: new(string), ValidValues: []string{"json", "yaml"}},
+		{Name: "resource", Completion: []string{"echo", "$namespace-pods"}},
+	}
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"first", []string{}, []string{"k8s-a", "k8s-b"}},
+		{"valid values", []string{"k8s-a"}, []string{"json", "yaml"}},
+		{"after flag", []string{"--output"}, []string{"json", "yaml"}},
+		{"skip flags", []string{"--namespace", "ns", "--output=json"}, []string{"ns-pods"}},
+		{"done", []string{"a", "json", "pods"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := argCompletions(defs, tt.args, map[string]string{"provider": "k8s"})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
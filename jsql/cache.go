@@ -1,17 +1,270 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DavidGamba/dgtools/clitable"
+	"github.com/DavidGamba/go-getoptions"
 )
 
-func createCacheDir(subDir string) (fullpath string, err error) {
+// cacheMetadata - Records when and with which args the data in a cache file was fetched.
+// The cached data is only reused for the same args, kube context and command definition.
+type cacheMetadata struct {
+	Provider    string    `json:"provider"`
+	Command     string    `json:"command"`
+	Args        []string  `json:"args"`
+	Context     string    `json:"context,omitempty"` // kube context/namespace for k8s commands
+	CommandHash string    `json:"command_hash"`      // hash of the rendered command definition
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// sameSource - Returns true if the data was fetched with the same args, kube context and command definition.
+func (m *cacheMetadata) sameSource(o *cacheMetadata) bool {
+	return slices.Equal(m.Args, o.Args) && m.Context == o.Context && m.CommandHash == o.CommandHash
+}
+
+// commandHash - Returns a hash of the command definition with its placeholders replaced.
+// Changes to the command, its filter or its k8s resource invalidate the cached data.
+func commandHash(commandData GetCommand, expand func(string) string) (string, error) {
+	rendered := struct {
+		Type          string
+		Command       []string
+		Filter        []string
+		Resource      string
+		AllNamespaces bool
+	}{
+		Type:          commandData.Type,
+		Resource:      expand(commandData.Resource),
+		AllNamespaces: commandData.AllNamespaces,
+	}
+	for _, e := range commandData.Command {
+		rendered.Command = append(rendered.Command, expand(e))
+	}
+	for _, e := range commandData.Filter {
+		rendered.Filter = append(rendered.Filter, expand(e))
+	}
+	b, err := json.Marshal(rendered)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal command definition: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+func cacheBaseDir() (string, error) {
 	cacheDirBase, err := os.UserCacheDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user cache dir: %w", err)
 	}
-	cacheDir := filepath.Join(cacheDirBase, "jsql", subDir)
-	os.MkdirAll(cacheDir, 0755)
+	return filepath.Join(cacheDirBase, "jsql"), nil
+}
+
+func createCacheDir(subDir string) (fullpath string, err error) {
+	cacheDirBase, err := cacheBaseDir()
+	if err != nil {
+		return "", err
+	}
+	cacheDir := filepath.Join(cacheDirBase, subDir)
+	err = os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	}
 	return cacheDir, nil
 }
+
+// cacheFilename - Returns the cache file for the command data, each set of args gets its own file.
+func cacheFilename(cacheDir, command string, args []string) string {
+	name := command
+	for _, arg := range args {
+		name += "_" + strings.Map(func(r rune) rune {
+			if r == '/' || r == os.PathSeparator || r == ' ' {
+				return '-'
+			}
+			return r
+		}, arg)
+	}
+	return filepath.Join(cacheDir, name+".json")
+}
+
+func metadataFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".meta.json"
+}
+
+func readCacheMetadata(filename string) (*cacheMetadata, error) {
+	b, err := os.ReadFile(metadataFilename(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache metadata: %w", err)
+	}
+	meta := &cacheMetadata{}
+	err = json.Unmarshal(b, meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cache metadata: %w", err)
+	}
+	return meta, nil
+}
+
+func writeCacheMetadata(filename string, meta *cacheMetadata) error {
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache metadata: %w", err)
+	}
+	err = os.WriteFile(metadataFilename(filename), b, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	return nil
+}
+
+// cacheFresh - Returns the cache metadata if the cached data was fetched from the same source less than ttl ago.
+func cacheFresh(filename string, source *cacheMetadata, ttl time.Duration) (*cacheMetadata, bool) {
+	if ttl <= 0 {
+		return nil, false
+	}
+	if _, err := os.Stat(filename); err != nil {
+		return nil, false
+	}
+	meta, err := readCacheMetadata(filename)
+	if err != nil {
+		Logger.Printf("Ignoring cache: %s", err)
+		return nil, false
+	}
+	if !meta.sameSource(source) || time.Since(meta.FetchedAt) > ttl {
+		return meta, false
+	}
+	return meta, true
+}
+
+// cacheEntry - A cached dataset.
+type cacheEntry struct {
+	Provider string
+	Filename string
+	Meta     *cacheMetadata // nil if the data was fetched by an older version
+	Age      time.Duration
+	Size     int64
+}
+
+func (e cacheEntry) command() string {
+	if e.Meta != nil {
+		return e.Meta.Command
+	}
+	return strings.TrimSuffix(filepath.Base(e.Filename), ".json")
+}
+
+// cacheEntries - Returns the cached datasets, optionally only the ones for the given provider.
+func cacheEntries(provider string) ([]cacheEntry, error) {
+	base, err := cacheBaseDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := os.ReadDir(base)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache dir: %w", err)
+	}
+	entries := []cacheEntry{}
+	for _, dir := range dirs {
+		if !dir.IsDir() || (provider != "" && dir.Name() != provider) {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(base, dir.Name(), "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cache files: %w", err)
+		}
+		for _, filename := range files {
+			if strings.HasSuffix(filename, ".meta.json") {
+				continue
+			}
+			info, err := os.Stat(filename)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat cache file: %w", err)
+			}
+			e := cacheEntry{Provider: dir.Name(), Filename: filename, Size: info.Size(), Age: time.Since(info.ModTime())}
+			meta, err := readCacheMetadata(filename)
+			if err == nil {
+				e.Meta = meta
+				e.Age = time.Since(meta.FetchedAt)
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func CacheListRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+	provider := ""
+	if len(args) > 0 {
+		provider = args[0]
+	}
+	entries, err := cacheEntries(provider)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "No cached data\n")
+		return nil
+	}
+	data := [][]string{{"Provider", "Command", "Args", "Context", "Age", "Size", "File"}}
+	for _, e := range entries {
+		a, c := "", ""
+		if e.Meta != nil {
+			a = strings.Join(e.Meta.Args, " ")
+			c = e.Meta.Context
+		}
+		data = append(data, []string{e.Provider, e.command(), a, c, e.Age.Round(time.Second).String(), humanSize(e.Size), e.Filename})
+	}
+	return clitable.NewTablePrinter().Print(clitable.SimpleTable{Data: data})
+}
+
+func CacheClearRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+	provider, command := "", ""
+	if len(args) > 0 {
+		provider = args[0]
+	}
+	if len(args) > 1 {
+		command = args[1]
+	}
+	entries, err := cacheEntries(provider)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if command != "" && e.command() != command {
+			continue
+		}
+		Logger.Printf("Removing %s", e.Filename)
+		err := os.Remove(e.Filename)
+		if err != nil {
+			return fmt.Errorf("failed to remove cache file: %w", err)
+		}
+		err = os.Remove(metadataFilename(e.Filename))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove cache metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+func humanSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	s := float64(size)
+	i := 0
+	for s >= 1024 && i < len(units)-1 {
+		s /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", s, units[i])
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheFresh(t *testing.T) {
+	dir := t.TempDir()
+	filename := cacheFilename(dir, "get", []string{"pods"})
+	if filepath.Base(filename) != "get_pods.json" {
+		t.Errorf("wrong filename: %s", filename)
+	}
+
+	source := &cacheMetadata{Args: []string{"pods"}, Context: "dev/default", CommandHash: "abc"}
+	if _, ok := cacheFresh(filename, source, time.Hour); ok {
+		t.Errorf("missing cache reported as fresh")
+	}
+
+	err := os.WriteFile(filename, []byte("[]"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = writeCacheMetadata(filename, &cacheMetadata{Provider: "k8s", Command: "get", Args: []string{"pods"}, Context: "dev/default", CommandHash: "abc", FetchedAt: time.Now().Add(-10 * time.Minute)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		source cacheMetadata
+		ttl    time.Duration
+		fresh  bool
+	}{
+		{"fresh", *source, time.Hour, true},
+		{"expired", *source, time.Minute, false},
+		{"no ttl", *source, 0, false},
+		{"different args", cacheMetadata{Args: []string{"nodes"}, Context: "dev/default", CommandHash: "abc"}, time.Hour, false},
+		{"different context", cacheMetadata{Args: []string{"pods"}, Context: "prod/default", CommandHash: "abc"}, time.Hour, false},
+		{"different namespace", cacheMetadata{Args: []string{"pods"}, Context: "dev/kube-system", CommandHash: "abc"}, time.Hour, false},
+		{"different command", cacheMetadata{Args: []string{"pods"}, Context: "dev/default", CommandHash: "def"}, time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := cacheFresh(filename, &tt.source, tt.ttl)
+			if ok != tt.fresh {
+				t.Errorf("got %v, want %v", ok, tt.fresh)
+			}
+		})
+	}
+}
+
+func TestCommandHash(t *testing.T) {
+	expand := func(s string) string {
+		return replacePlaceholders(s, map[string]string{"resource": "pods"})
+	}
+	base := GetCommand{Type: "k8s", Resource: "$resource"}
+	h1, err := commandHash(base, expand)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	h2, err := commandHash(GetCommand{Type: "k8s", Resource: "pods"}, expand)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if h1 != h2 {
+		t.Errorf("same rendered command with different hashes: %s, %s", h1, h2)
+	}
+	h3, err := commandHash(GetCommand{Type: "k8s", Resource: "$resource", AllNamespaces: true}, expand)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if h1 == h3 {
+		t.Errorf("different commands with the same hash: %s", h1)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"slices"
 	"strings"
 )
@@ -38,9 +41,18 @@ var (
 		"USE",
 		"VACUUM",
 
+		".attach",
+		".detach",
+		".export",
+		".get",
 		".help",
 		".mode",
 		".output",
+		".run",
+		".save",
+		".schema",
+		".tables",
+		".view",
 	}
 
 	createKeywords = []string{
@@ -104,21 +116,246 @@ func AllKeywords() []string {
 	return slices.Compact(keywords)
 }
 
-func completionCandidates(fieldsBeforeCursor []string) (completionSet []string, listingSet []string) {
+// Completer - Provides the REPL completion candidates.
+// Tables, columns and macros are loaded from the DB with Refresh.
+type Completer struct {
+	tables    []string            // table names, qualified with the schema when not in main and with the DB when attached
+	columns   map[string][]string // table name, both qualified and not, to column paths
+	macros    []string            // user defined macros
+	queries   []string            // saved query names
+	databases []string            // DBs attached with .attach
+}
+
+// Refresh - Reloads the tables, columns and macros from the DB.
+func (c *Completer) Refresh(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, `SELECT table_catalog, table_schema, table_name, column_name, data_type, table_catalog = current_database()
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('information_schema', 'pg_catalog')
+		ORDER BY table_catalog, table_schema, table_name, ordinal_position`)
+	if err != nil {
+		return fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+	tables := []string{}
+	columns := map[string][]string{}
+	for rows.Next() {
+		var catalog, schema, table, column, dataType string
+		var current bool
+		if err := rows.Scan(&catalog, &schema, &table, &column, &dataType, &current); err != nil {
+			return fmt.Errorf("failed to scan column: %w", err)
+		}
+		name := table
+		if schema != "main" {
+			name = schema + "." + table
+		}
+		if !current {
+			name = catalog + "." + name
+		}
+		if _, ok := columns[name]; !ok {
+			tables = append(tables, name)
+		}
+		paths := []string{column}
+		for _, field := range structFields(dataType) {
+			paths = append(paths, column+"."+field)
+		}
+		columns[name] = append(columns[name], paths...)
+		if name != table {
+			columns[table] = append(columns[table], paths...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query columns: %w", err)
+	}
+
+	macros, err := queryMacros(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	databases, err := queryDatabases(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	c.tables, c.columns, c.macros, c.databases = tables, columns, macros, databases
+	return nil
+}
+
+// queryDatabases - Returns the attached DBs.
+func queryDatabases(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT database_name
+		FROM duckdb_databases()
+		WHERE NOT internal AND database_name != current_database()
+		ORDER BY database_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+	defer rows.Close()
+	databases := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan database: %w", err)
+		}
+		databases = append(databases, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+	return databases, nil
+}
+
+// queryMacros - Returns the user defined macros, like the ones in the provider Macros.
+func queryMacros(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT DISTINCT schema_name, function_name
+		FROM duckdb_functions()
+		WHERE function_type IN ('macro', 'table_macro') AND NOT internal
+		ORDER BY schema_name, function_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query macros: %w", err)
+	}
+	defer rows.Close()
+	macros := []string{}
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan macro: %w", err)
+		}
+		if schema != "main" {
+			name = schema + "." + name
+		}
+		macros = append(macros, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query macros: %w", err)
+	}
+	return macros, nil
+}
+
+// allColumns - Returns the column paths of all the tables.
+func (c *Completer) allColumns() []string {
+	all := []string{}
+	for _, table := range c.tables {
+		all = append(all, c.columns[table]...)
+	}
+	return all
+}
+
+func (c *Completer) Candidates(fieldsBeforeCursor []string) (completionSet []string, listingSet []string) {
+	// Complete the columns of table. and schema.table.
+	if len(fieldsBeforeCursor) > 0 {
+		// The word being completed can be inside a function call or a list: count(pods.
+		word := fieldsBeforeCursor[len(fieldsBeforeCursor)-1]
+		head, word := word[:strings.LastIndexAny(word, "(,")+1], word[strings.LastIndexAny(word, "(,")+1:]
+		if i := strings.LastIndex(word, "."); i > 0 {
+			if columns, ok := c.columns[word[:i]]; ok {
+				candidates := []string{}
+				for _, column := range columns {
+					candidates = append(candidates, head+word[:i]+"."+column)
+				}
+				return candidates, candidates
+			}
+		}
+	}
+
 	candidates := commands
 	for _, word := range fieldsBeforeCursor {
 		if strings.EqualFold(word, ".mode") {
-			candidates = []string{"pretty", "single_line", "table", "csv"}
+			candidates = []string{"pretty", "single_line", "table", "csv", "markdown", "vertical"}
 		}
 		if strings.EqualFold(word, ".output") {
 			candidates = []string{"stdout", "file"}
 		}
-		if strings.EqualFold(word, "SELECT") {
+		if strings.EqualFold(word, ".run") {
+			candidates = c.queries
+		}
+		if strings.EqualFold(word, ".schema") {
+			candidates = c.tables
+		}
+		if strings.EqualFold(word, ".detach") {
+			candidates = c.databases
+		}
+		if strings.EqualFold(word, "SELECT") || strings.EqualFold(word, "WHERE") {
 			candidates = append(candidates, selectKeywords...)
+			candidates = append(candidates, c.allColumns()...)
+			candidates = append(candidates, c.macros...)
+		}
+		if strings.EqualFold(word, "FROM") || strings.EqualFold(word, "JOIN") {
+			candidates = append(candidates, c.tables...)
+			candidates = append(candidates, c.macros...)
 		}
 		if strings.EqualFold(word, "CREATE") {
 			candidates = append(candidates, createKeywords...)
 		}
 	}
+	candidates = slices.Clone(candidates)
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
 	return candidates, candidates
 }
+
+// structFields - Returns the field paths of a STRUCT data type, including the fields of nested structs.
+//
+//	STRUCT("name" VARCHAR, labels MAP(VARCHAR, VARCHAR), status STRUCT(phase VARCHAR))
+//	=> name, labels, status, status.phase
+func structFields(dataType string) []string {
+	if !strings.HasPrefix(dataType, "STRUCT(") || !strings.HasSuffix(dataType, ")") {
+		return nil
+	}
+	fields := []string{}
+	for _, part := range splitTopLevel(dataType[len("STRUCT(") : len(dataType)-1]) {
+		name, fieldType := splitField(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		fields = append(fields, name)
+		for _, sub := range structFields(fieldType) {
+			fields = append(fields, name+"."+sub)
+		}
+	}
+	return fields
+}
+
+// splitTopLevel - Splits the STRUCT field list on the commas outside of nested types and quotes.
+func splitTopLevel(s string) []string {
+	parts := []string{}
+	depth, start, quoted := 0, 0, false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitField - Splits a STRUCT field into its name and type.
+func splitField(field string) (name, fieldType string) {
+	if strings.HasPrefix(field, `"`) {
+		end := 1
+		for end < len(field) {
+			if field[end] == '"' {
+				if end+1 < len(field) && field[end+1] == '"' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		if end >= len(field) {
+			return "", ""
+		}
+		return strings.ReplaceAll(field[1:end], `""`, `"`), strings.TrimSpace(field[end+1:])
+	}
+	name, fieldType, _ = strings.Cut(field, " ")
+	return name, strings.TrimSpace(fieldType)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+)
+
+func TestStructFields(t *testing.T) {
+	got := structFields(`STRUCT("name" VARCHAR, labels MAP(VARCHAR, VARCHAR), "a ""b""" INTEGER, status STRUCT(phase VARCHAR, conditions STRUCT("type" VARCHAR)[]))`)
+	want := []string{"name", "labels", `a "b"`, "status", "status.phase", "status.conditions"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := structFields("VARCHAR"); got != nil {
+		t.Errorf("got %q, want nil", got)
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+	for _, q := range []string{
+		"CREATE SCHEMA k8s;",
+		"CREATE TABLE k8s.pods (name VARCHAR, metadata STRUCT(name VARCHAR, namespace VARCHAR));",
+		"CREATE TABLE notes (id INTEGER);",
+		"CREATE MACRO k8s.age(x) AS x;",
+	} {
+		err := execQuery(ctx, conn, q)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	c := &Completer{}
+	err = c.Refresh(ctx, conn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+		absent []string
+	}{
+		{"tables", []string{"SELECT", "*", "FROM", ""}, []string{"k8s.pods", "notes", "k8s.age"}, nil},
+		{"columns", []string{"SELECT", ""}, []string{"name", "metadata", "metadata.name", "id", "k8s.age"}, []string{"k8s.pods"}},
+		{"table columns", []string{"SELECT", "pods."}, []string{"pods.name", "pods.metadata.namespace"}, []string{"id"}},
+		{"schema table columns", []string{"SELECT", "count(k8s.pods."}, []string{"count(k8s.pods.metadata.name"}, nil},
+		{"commands", []string{""}, []string{"SELECT", ".get"}, []string{"notes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := c.Candidates(tt.fields)
+			for _, w := range tt.want {
+				if !slices.Contains(got, w) {
+					t.Errorf("missing %q in %q", w, got)
+				}
+			}
+			for _, a := range tt.absent {
+				if slices.Contains(got, a) {
+					t.Errorf("unexpected %q in %q", a, got)
+				}
+			}
+		})
+	}
+}
@@ -20,29 +20,36 @@ var configFilename = "jsql.cue"
 
 type Config struct {
 	Provider map[string]ConfigProvider
+	Queries  map[string]string // saved queries by name
+	DB       string            `json:"db"` // DB file, defaults to DBNAME in the current dir
 }
 
 type ConfigProvider struct {
 	Name        string
-	SchemaName  string `json:"schemaName"`
-	GetCommands map[string]struct {
-		Name    string
-		Table   string
-		Command []string
-		Filter  []string
-		Create  []string
-		Args    []struct {
-			Name        string
-			Description string
-		}
-	} `json:"getCommands"`
-	Macros []string
-	Views  map[string]struct {
+	SchemaName  string                `json:"schemaName"`
+	GetCommands map[string]GetCommand `json:"getCommands"`
+	Macros      []string
+	Views       map[string]struct {
 		Dependencies []string
 		Queries      []string
 	}
 }
 
+type GetCommand struct {
+	Name    string
+	Type    string // command or a native reader like k8s
+	Table   string
+	Command []string
+	Filter  []string
+	Create  []string
+	TTL     string
+	Args    []CommandArg
+
+	// k8s type
+	Resource      string
+	AllNamespaces bool `json:"allNamespaces"`
+}
+
 func ConfigRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
 	Logger.Printf("Running")
 	d := &Config{}
@@ -110,6 +117,20 @@ func ReadConfig(data *Config, value *cue.Value) error {
 	if err != nil {
 		return fmt.Errorf("failed to print value: %w", err)
 	}
+
+	// Queries saved with .save, the ones in the config take precedence
+	saved, err := readSavedQueries()
+	if err != nil {
+		return err
+	}
+	if data.Queries == nil {
+		data.Queries = map[string]string{}
+	}
+	for name, query := range saved {
+		if _, ok := data.Queries[name]; !ok {
+			data.Queries[name] = query
+		}
+	}
 	// Logger.Printf("value:\n%v\n", string(v))
 
 	// Logger.Printf("data structure:\n%+v\n", data)
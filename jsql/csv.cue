@@ -8,9 +8,9 @@ provider: csv: {
 			{name: "file", description: "CSV file"}
 		]
 		create: [
-			"CREATE SCHEMA IF NOT EXISTS $arg1;"
-			"DROP TABLE IF EXISTS $arg1;"
-			"CREATE TABLE $arg1 AS SELECT * FROM read_csv('$arg2')"
+			"CREATE SCHEMA IF NOT EXISTS $name;"
+			"DROP TABLE IF EXISTS $name;"
+			"CREATE TABLE $name AS SELECT * FROM read_csv('$file')"
 		]
 	}
 }
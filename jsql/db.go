@@ -7,12 +7,50 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/DavidGamba/dgtools/clitable"
+	"github.com/DavidGamba/go-getoptions"
 )
 
-func dbConn(ctx context.Context) (*sql.Conn, error) {
-	db, err := sql.Open("duckdb", DBNAME)
+// dbFilename - Returns the DB file to use, from the --db option, the config db field or DBNAME in the current dir.
+func dbFilename(opt *getoptions.GetOpt) (string, error) {
+	filename, _ := opt.Value("db").(string)
+	if filename == "" {
+		d := &Config{}
+		err := ReadConfig(d, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to read config: %w", err)
+		}
+		filename = d.DB
+	}
+	if filename == "" {
+		filename = DBNAME
+	}
+	return dbPath(filename)
+}
+
+// dbPath - Expands ~/ to the home dir and creates the parent dir of the DB file.
+func dbPath(filename string) (string, error) {
+	if filename == "~" || strings.HasPrefix(filename, "~/") {
+		filename = filepath.Join(os.Getenv("HOME"), filename[1:])
+	}
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create db dir: %w", err)
+	}
+	return filename, nil
+}
+
+func dbConn(ctx context.Context, opt *getoptions.GetOpt) (*sql.Conn, error) {
+	filename, err := dbFilename(opt)
+	if err != nil {
+		return nil, err
+	}
+	Logger.Printf("Using DB: %s", filename)
+	db, err := sql.Open("duckdb", filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed: %w", err)
 	}
@@ -23,6 +61,16 @@ func dbConn(ctx context.Context) (*sql.Conn, error) {
 	return conn, nil
 }
 
+// execQuery - Runs a statement that doesn't return rows, like the provider Create and Macros statements.
+func execQuery(ctx context.Context, conn *sql.Conn, query string) error {
+	Logger.Printf("Running query: %s\n", query)
+	_, err := conn.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to run %q: %w", query, err)
+	}
+	return nil
+}
+
 func runQuery(ctx context.Context, w io.Writer, conn *sql.Conn, mode outputMode, qo queryOption, query string) error {
 	rows, err := conn.QueryContext(ctx, query)
 	if err != nil {
@@ -87,9 +135,69 @@ func runQuery(ctx context.Context, w io.Writer, conn *sql.Conn, mode outputMode,
 		clitable.NewTablePrinter().Fprint(w, clitable.MapTable{MapList: results})
 	case outputModeCSV:
 		clitable.NewTablePrinter().SetStyle(clitable.CSV).Fprint(w, clitable.MapTable{MapList: results})
+	case outputModeMarkdown:
+		writeMarkdown(w, orderedColumns(cols, qo), results)
+	case outputModeVertical:
+		writeVertical(w, orderedColumns(cols, qo), results)
 	default:
 		return fmt.Errorf("unknown output mode: %q", mode)
 	}
 	fmt.Fprintf(os.Stderr, "query rows: %d\n", rowCount)
 	return nil
 }
+
+// orderedColumns - Returns the query columns in order, with the numbering column first when enabled.
+func orderedColumns(cols []string, qo queryOption) []string {
+	if qo&queryOptionAutoNumber != 0 {
+		return append([]string{"_idx"}, cols...)
+	}
+	return cols
+}
+
+// tablesQuery - Lists the tables and views of the DB and the attached DBs.
+const tablesQuery = `SELECT database_name AS database, schema_name AS schema, table_name AS name, 'table' AS type
+	FROM duckdb_tables() WHERE NOT internal
+	UNION ALL
+	SELECT database_name, schema_name, view_name, 'view'
+	FROM duckdb_views() WHERE NOT internal
+	ORDER BY ALL`
+
+// tableSchema - Returns the CREATE statements of the tables and views matching name, all of them if name is empty.
+// The name can be qualified with the schema and the database: table, schema.table, database.table or database.schema.table.
+func tableSchema(ctx context.Context, conn *sql.Conn, name string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT database_name, schema_name, table_name, sql FROM duckdb_tables() WHERE NOT internal
+		UNION ALL
+		SELECT database_name, schema_name, view_name, sql FROM duckdb_views() WHERE NOT internal
+		ORDER BY ALL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema: %w", err)
+	}
+	defer rows.Close()
+	statements := []string{}
+	for rows.Next() {
+		var database, schema, table, stmt string
+		if err := rows.Scan(&database, &schema, &table, &stmt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema: %w", err)
+		}
+		if name != "" && !slices.Contains([]string{table, schema + "." + table, database + "." + table, database + "." + schema + "." + table}, name) {
+			continue
+		}
+		statements = append(statements, stmt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query schema: %w", err)
+	}
+	if name != "" && len(statements) == 0 {
+		return nil, fmt.Errorf("table not found: %s", name)
+	}
+	return statements, nil
+}
+
+// attachQuery - Returns the statement to attach the DB file, as name when given.
+func attachQuery(filename, name string) string {
+	query := fmt.Sprintf("ATTACH '%s'", strings.ReplaceAll(filename, "'", "''"))
+	if name != "" {
+		query += fmt.Sprintf(` AS "%s"`, strings.ReplaceAll(name, `"`, `""`))
+	}
+	return query
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDBPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	got, err := dbPath("~/.jsql/k8s.duckdb")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := filepath.Join(home, ".jsql", "k8s.duckdb"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	if got, want := attachQuery("it's.duckdb", ""), `ATTACH 'it''s.duckdb'`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := attachQuery("a.duckdb", "other"), `ATTACH 'a.duckdb' AS "other"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	other, err := sql.Open("duckdb", filepath.Join(dir, "other.duckdb"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = other.ExecContext(ctx, "CREATE TABLE nodes (name VARCHAR);")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	other.Close()
+
+	db, err := sql.Open("duckdb", filepath.Join(dir, "jsql.duckdb"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+	for _, q := range []string{
+		"CREATE SCHEMA k8s;",
+		"CREATE TABLE k8s.pods (name VARCHAR);",
+		"CREATE VIEW pn AS SELECT name FROM k8s.pods;",
+		attachQuery(filepath.Join(dir, "other.duckdb"), "other"),
+	} {
+		err := execQuery(ctx, conn, q)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	statements, err := tableSchema(ctx, conn, "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(statements) != 1 || !strings.HasPrefix(statements[0], "CREATE TABLE k8s.pods") {
+		t.Errorf("unexpected schema: %q", statements)
+	}
+	statements, err = tableSchema(ctx, conn, "other.nodes")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(statements) != 1 || !strings.Contains(statements[0], "nodes") {
+		t.Errorf("unexpected schema: %q", statements)
+	}
+	statements, err = tableSchema(ctx, conn, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(statements) != 3 {
+		t.Errorf("unexpected schema: %q", statements)
+	}
+	_, err = tableSchema(ctx, conn, "missing")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	c := &Completer{}
+	err = c.Refresh(ctx, conn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Contains(c.tables, "other.nodes") || !slices.Contains(c.tables, "k8s.pods") {
+		t.Errorf("unexpected tables: %q", c.tables)
+	}
+	if !slices.Equal(c.databases, []string{"other"}) {
+		t.Errorf("unexpected databases: %q", c.databases)
+	}
+
+	err = execQuery(ctx, conn, `DETACH "other"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = c.Refresh(ctx, conn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if slices.Contains(c.tables, "other.nodes") || len(c.databases) != 0 {
+		t.Errorf("unexpected tables after detach: %q %q", c.tables, c.databases)
+	}
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/DavidGamba/dgtools/run"
 	"github.com/DavidGamba/go-getoptions"
@@ -13,15 +13,26 @@ import (
 
 func GetRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
 	Logger.Printf("Running")
-	return GetExec(ctx, args)
+
+	conn, err := dbConn(ctx, opt)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = GetExec(ctx, conn, args, opt.Value("refresh").(bool))
+	return err
 }
 
-func GetExec(ctx context.Context, args []string) error {
+// GetExec - Runs the provider's Get command and loads its data into the DB.
+// The data is cached and reused while it is fresher than the command TTL, unless refresh is set.
+// Returns the name of the table loaded, empty if only the provider or command list was printed.
+func GetExec(ctx context.Context, conn *sql.Conn, args []string, refresh bool) (string, error) {
 	// Get config
 	d := &Config{}
 	err := ReadConfig(d, nil)
 	if err != nil {
-		return fmt.Errorf("failed to read config: %w", err)
+		return "", fmt.Errorf("failed to read config: %w", err)
 	}
 
 	// If no arguments are provided list all providers
@@ -30,13 +41,13 @@ func GetExec(ctx context.Context, args []string) error {
 		for k := range d.Provider {
 			fmt.Printf("%s\n", k)
 		}
-		return nil
+		return "", nil
 	}
 
 	// get and validate provider
 	provider, args := args[0], args[1:]
 	if _, ok := d.Provider[provider]; !ok {
-		return fmt.Errorf("invalid provider: %s", provider)
+		return "", fmt.Errorf("invalid provider: %s", provider)
 	}
 
 	// If no arguments are provided list all commands for the provider
@@ -45,96 +56,134 @@ func GetExec(ctx context.Context, args []string) error {
 		for _, cmd := range d.Provider[provider].GetCommands {
 			fmt.Printf("%s\n", cmd.Name)
 		}
-		return nil
+		return "", nil
 	}
 
 	// get and validate command
 	command, args := args[0], args[1:]
 	if _, ok := d.Provider[provider].GetCommands[command]; !ok {
-		return fmt.Errorf("invalid command: %s", command)
+		return "", fmt.Errorf("invalid command: %s", command)
 	}
 
 	// validate arguments
-	if len(d.Provider[provider].GetCommands[command].Args) != len(args) {
-		fmt.Fprintf(os.Stderr, "Usage:\n    %s %s", provider, command)
-		for _, arg := range d.Provider[provider].GetCommands[command].Args {
-			fmt.Fprintf(os.Stderr, " <%s>", arg.Name)
-		}
-		fmt.Fprintf(os.Stderr, "\n\nARGUMENTS:\n")
-		for _, arg := range d.Provider[provider].GetCommands[command].Args {
-			fmt.Fprintf(os.Stderr, "    %-20s %s\n", arg.Name, arg.Description)
-		}
-		return fmt.Errorf("missing args")
+	commandData := d.Provider[provider].GetCommands[command]
+	args, named, err := parseArgs(commandData.Args, args)
+	if err != nil {
+		printArgsUsage(os.Stderr, provider, command, commandData.Args)
+		return "", err
 	}
+	placeholders := argPlaceholders(args, named)
+	placeholders["schemaName"] = d.Provider[provider].SchemaName
+	placeholders["provider"] = provider
+	placeholders["command"] = command
 
 	// Replace placeholders in table name
-	table := d.Provider[provider].GetCommands[command].Table
-	table = strings.ReplaceAll(table, "$schemaName", d.Provider[provider].SchemaName)
-	table = strings.ReplaceAll(table, "$provider", provider)
-	if len(args) > 0 {
-		table = strings.ReplaceAll(table, "$arg1", args[0])
-	}
-	if len(args) > 1 {
-		table = strings.ReplaceAll(table, "$arg2", args[1])
-	}
+	table := replacePlaceholders(commandData.Table, placeholders)
+	placeholders["table"] = table
 
 	Logger.Printf("Provider: %s, Command: %s, Args: %v", provider, command, args)
 
 	// Create cache dir
 	cacheDir, err := createCacheDir(provider)
 	if err != nil {
-		return fmt.Errorf("failed to get cache dir: %w", err)
+		return "", fmt.Errorf("failed to get cache dir: %w", err)
 	}
 	Logger.Printf("Using cache dir: %s", cacheDir)
 
-	filename := filepath.Join(cacheDir, command+".json")
+	filename := cacheFilename(cacheDir, command, args)
+	placeholders["filename"] = filename
 
-	// Run get command
-	commandData := d.Provider[provider].GetCommands[command]
-	commandParts := commandData.Command
-	Logger.Printf("data: %v\n", commandData)
-	for i, e := range commandParts {
-		if len(args) > 0 {
-			e = strings.ReplaceAll(e, "$arg1", args[0])
+	// Replace placeholders in commands and queries
+	expand := func(e string) string {
+		return replacePlaceholders(e, placeholders)
+	}
+
+	// Run the get command unless the cached data is fresh
+	var ttl time.Duration
+	if commandData.TTL != "" {
+		ttl, err = time.ParseDuration(commandData.TTL)
+		if err != nil {
+			return "", fmt.Errorf("invalid ttl for %s %s: %w", provider, command, err)
 		}
-		if len(args) > 1 {
-			e = strings.ReplaceAll(e, "$arg2", args[1])
+	}
+	source := &cacheMetadata{Provider: provider, Command: command, Args: args}
+	source.CommandHash, err = commandHash(commandData, expand)
+	if err != nil {
+		return "", err
+	}
+	if commandData.Type == "k8s" {
+		// The same resource in a different cluster or namespace is different data
+		kctx, namespace, err := GetK8sContext(ctx)
+		if err != nil {
+			return "", err
 		}
-		e = strings.ReplaceAll(e, "$table", table)
-		e = strings.ReplaceAll(e, "$schemaName", d.Provider[provider].SchemaName)
-		e = strings.ReplaceAll(e, "$provider", provider)
-		e = strings.ReplaceAll(e, "$command", command)
-		e = strings.ReplaceAll(e, "$filename", filename)
-		commandParts[i] = e
+		source.Context = kctx + "/" + namespace
 	}
-	var out []byte
-	if len(commandParts) > 0 {
-		Logger.Printf("Running command: %v\n", commandParts)
-		out, err = run.CMD(commandParts...).Log().STDOutOutput()
+	if meta, ok := cacheFresh(filename, source, ttl); ok && !refresh {
+		Logger.Printf("Using cached data fetched %s ago: %s", time.Since(meta.FetchedAt).Round(time.Second), filename)
+	} else {
+		err = fetchData(ctx, commandData, expand, filename)
 		if err != nil {
-			return fmt.Errorf("failed: %w", err)
+			return "", err
+		}
+		source.FetchedAt = time.Now()
+		err = writeCacheMetadata(filename, source)
+		if err != nil {
+			return "", err
 		}
 	}
 
-	// Run command data filter
-	filterParts := commandData.Filter
-	for i, e := range filterParts {
-		if len(args) > 0 {
-			e = strings.ReplaceAll(e, "$arg1", args[0])
+	for _, e := range commandData.Create {
+		err = execQuery(ctx, conn, expand(e))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, e := range d.Provider[provider].Macros {
+		err = execQuery(ctx, conn, expand(e))
+		if err != nil {
+			return "", err
 		}
-		if len(args) > 1 {
-			e = strings.ReplaceAll(e, "$arg2", args[1])
+	}
+
+	return table, nil
+}
+
+// fetchData - Runs the get command, or the native reader for the command type, and its data filter and saves the output to filename.
+func fetchData(ctx context.Context, commandData GetCommand, expand func(string) string, filename string) error {
+	var out []byte
+	var err error
+
+	switch commandData.Type {
+	case "k8s":
+		out, err = k8sList(ctx, expand(commandData.Resource), commandData.AllNamespaces)
+		if err != nil {
+			return err
 		}
-		e = strings.ReplaceAll(e, "$table", table)
-		e = strings.ReplaceAll(e, "$schemaName", d.Provider[provider].SchemaName)
-		e = strings.ReplaceAll(e, "$provider", provider)
-		e = strings.ReplaceAll(e, "$command", command)
-		e = strings.ReplaceAll(e, "$filename", filename)
-		filterParts[i] = e
-	}
-	if len(filterParts) > 0 {
-		Logger.Printf("Running command: %v\n", filterParts)
-		out, err = run.CMD(filterParts...).In(out).Log().STDOutOutput()
+	default:
+		// Run get command
+		command := commandData.Command
+		for i, e := range command {
+			command[i] = expand(e)
+		}
+		if len(command) > 0 {
+			Logger.Printf("Running command: %v\n", command)
+			out, err = run.CMD(command...).Log().STDOutOutput()
+			if err != nil {
+				return fmt.Errorf("failed: %w", err)
+			}
+		}
+	}
+
+	// Run command data filter
+	filter := commandData.Filter
+	for i, e := range filter {
+		filter[i] = expand(e)
+	}
+	if len(filter) > 0 {
+		Logger.Printf("Running command: %v\n", filter)
+		out, err = run.CMD(filter...).In(out).Log().STDOutOutput()
 		if err != nil {
 			return fmt.Errorf("failed: %w", err)
 		}
@@ -150,46 +199,5 @@ func GetExec(ctx context.Context, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to write to file: %w", err)
 	}
-
-	cmds := d.Provider[provider].GetCommands[command].Create
-	for _, e := range cmds {
-		if len(args) > 0 {
-			e = strings.ReplaceAll(e, "$arg1", args[0])
-		}
-		if len(args) > 1 {
-			e = strings.ReplaceAll(e, "$arg2", args[1])
-		}
-		e = strings.ReplaceAll(e, "$table", table)
-		e = strings.ReplaceAll(e, "$schemaName", d.Provider[provider].SchemaName)
-		e = strings.ReplaceAll(e, "$provider", provider)
-		e = strings.ReplaceAll(e, "$command", command)
-		e = strings.ReplaceAll(e, "$filename", filename)
-		cmd := []string{"duckdb", DBNAME, "-s", e}
-		err = run.CMD(cmd...).Log().Run()
-		if err != nil {
-			return fmt.Errorf("failed: %w", err)
-		}
-	}
-
-	cmds = d.Provider[provider].Macros
-	for _, e := range cmds {
-		if len(args) > 0 {
-			e = strings.ReplaceAll(e, "$arg1", args[0])
-		}
-		if len(args) > 1 {
-			e = strings.ReplaceAll(e, "$arg2", args[1])
-		}
-		e = strings.ReplaceAll(e, "$table", table)
-		e = strings.ReplaceAll(e, "$schemaName", d.Provider[provider].SchemaName)
-		e = strings.ReplaceAll(e, "$provider", provider)
-		e = strings.ReplaceAll(e, "$command", command)
-		e = strings.ReplaceAll(e, "$filename", filename)
-		cmd := []string{"duckdb", DBNAME, "-s", e}
-		err = run.CMD(cmd...).Log().Run()
-		if err != nil {
-			return fmt.Errorf("failed: %w", err)
-		}
-	}
-
 	return nil
 }
@@ -8,7 +8,7 @@ provider: gh: {
 	getCommands: repos: {
 		args: [{name: "org", description: "GitHub Organization"}]
 		command: [
-			"gh", "repo", "list", "$arg1"
+			"gh", "repo", "list", "$org"
 			"--limit", "10"
 			"--json", strings.Join([
 				"archivedAt"
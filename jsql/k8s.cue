@@ -5,10 +5,16 @@ provider: k8s: {
 	getCommands: {
 		let X = self
 		"get": {
-			args:    [{name: "resource", description: "Kubernetes Resource"}]
-			table:   "$arg1"
-			command: ["kubectl", "get", "-o", "json", "$arg1"]
-			filter:  ["qq", ".items", "-o", "json"]
+			args: [{
+				name:        "resource"
+				description: "Kubernetes Resource"
+				// Discovered with the current kube context, no kubectl required
+				completionType: "k8s-resources"
+			}]
+			table: "$resource"
+			// List the resource with the current kube context, no kubectl required
+			type:     "k8s"
+			resource: "$resource"
 			create: [
 				"CREATE SCHEMA IF NOT EXISTS $schemaName;"
 				"DROP TABLE IF EXISTS $schemaName.$table;"
@@ -21,11 +27,12 @@ provider: k8s: {
 			]
 		}
 		"getAll": {
-			args:    X.get.args
-			table:   X.get.table
-			command: ["kubectl", "get", "-o", "json", "-A", "$arg1"]
-			filter:  X.get.filter
-			create:  X.get.create
+			args:          X.get.args
+			table:         X.get.table
+			type:          "k8s"
+			resource:      X.get.resource
+			allNamespaces: true
+			create:        X.get.create
 		}
 	}
 
@@ -113,7 +120,7 @@ provider: k8s: {
 	]
 
 	views: common: {
-		dependencies: ["pods", "rs", "deploy", "sts", "nodes"]
+		dependencies: ["getAll pods", "getAll rs", "getAll deploy", "getAll sts", "getAll nodes"]
 		queries: [
 
 			"""
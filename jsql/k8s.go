@@ -2,59 +2,155 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/DavidGamba/dgtools/trees"
-	"github.com/DavidGamba/dgtools/yamlutils"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
+// GetK8sContext - Returns the current kube context and its namespace.
+// KUBECONFIG can be a list of files, merged like kubectl does.
 func GetK8sContext(ctx context.Context) (contextName, namespace string, err error) {
-	kubeconfig := os.Getenv("KUBECONFIG")
-	if kubeconfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		kubeconfig = filepath.Join(homeDir, ".kube", "config")
+	clientConfig := newClientConfig()
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load kube config: %w", err)
+	}
+	namespace, _, err = clientConfig.Namespace()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get namespace: %w", err)
+	}
+	return rawConfig.CurrentContext, namespace, nil
+}
+
+func newClientConfig() clientcmd.ClientConfig {
+	configLoadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(configLoadingRules, &clientcmd.ConfigOverrides{})
+}
+
+// NewRestConfig - Returns the client config and namespace for the current kube context.
+// KUBECONFIG can be a list of files, merged like kubectl does.
+func NewRestConfig() (*rest.Config, string, error) {
+	clientConfig := newClientConfig()
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kube config: %w", err)
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get namespace: %w", err)
+	}
+	return config, namespace, nil
+}
+
+// k8sResources - Returns the listable resources of the current kube context like kubectl api-resources --verbs=list -o name.
+// Resources outside the core group are qualified with their group, e.g. deployments.apps.
+func k8sResources() ([]string, error) {
+	config, _, err := NewRestConfig()
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
 	}
+	lists, err := discoveryClient.ServerPreferredResources()
+	// Partial results are returned when some groups fail, e.g. an unavailable metrics server
+	if err != nil && len(lists) == 0 {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	names := []string{}
+	for _, list := range discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list"}}, lists) {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			// Skip subresources like pods/log
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			name := r.Name
+			if gv.Group != "" {
+				name += "." + gv.Group
+			}
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
 
-	ymlList, err := yamlutils.NewFromFile(kubeconfig)
+// k8sList - Lists the resource items in the current namespace, or in all namespaces, as a JSON array.
+// The resource can be a name, a short name or resource.group, like in kubectl get.
+func k8sList(ctx context.Context, resource string, allNamespaces bool) ([]byte, error) {
+	config, namespace, err := NewRestConfig()
 	if err != nil {
-		err = fmt.Errorf("failed to get kube config: %w", err)
-		return
+		return nil, err
 	}
-	if len(ymlList) < 1 {
-		err = fmt.Errorf("no resources found in kubeconfig")
-		return
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
 	}
-	current, err := ymlList[0].GetString(false, []string{"current-context"})
+	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
-		err = fmt.Errorf("failed to get current context: %w", err)
-		return
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
-	contextName = current
 
-	kctxsI, _, err := trees.NavigateTree(false, ymlList[0].Tree, []string{"contexts"})
+	// Resolve short names like deploy or sts
+	cachedDiscovery := memory.NewMemCacheClient(discoveryClient)
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscovery), cachedDiscovery, nil)
+	gvr, err := mapper.ResourceFor(schema.ParseGroupResource(resource).WithVersion(""))
 	if err != nil {
-		err = fmt.Errorf("failed to get contexts: %w", err)
-		return
+		return nil, fmt.Errorf("failed to find resource '%s': %w", resource, err)
 	}
-	kctxs, ok := kctxsI.([]any)
-	if !ok {
-		err = fmt.Errorf("failed to convert contexts: %w", err)
-		return
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find kind for resource '%s': %w", resource, err)
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find mapping for resource '%s': %w", resource, err)
 	}
 
-	for _, kctx := range kctxs {
-		name := kctx.(map[string]any)["name"].(string)
-		if name != current {
-			continue
+	var ri dynamic.ResourceInterface = dynamicClient.Resource(gvr)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if allNamespaces {
+			namespace = metav1.NamespaceAll
+		}
+		ri = dynamicClient.Resource(gvr).Namespace(namespace)
+	}
+	Logger.Printf("Listing %s in namespace: '%s'", gvr, namespace)
+
+	items := []map[string]any{}
+	opts := metav1.ListOptions{Limit: 500}
+	for {
+		list, err := ri.List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+		}
+		for _, item := range list.Items {
+			items = append(items, item.Object)
 		}
-		c := kctx.(map[string]any)["context"]
-		ns := c.(map[string]any)["namespace"]
-		if ns != nil {
-			namespace = ns.(string)
+		opts.Continue = list.GetContinue()
+		if opts.Continue == "" {
+			break
 		}
 	}
 
-	return contextName, namespace, nil
+	out, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", gvr.Resource, err)
+	}
+	return out, nil
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestK8sList(t *testing.T) {
+	pod := func(ns, name string) map[string]any {
+		return map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"name": name, "namespace": ns}}
+	}
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"kind": "APIVersions", "versions": []string{"v1"}})
+	})
+	mux.HandleFunc("/apis", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"kind": "APIGroupList", "apiVersion": "v1", "groups": []any{}})
+	})
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"kind": "APIResourceList", "groupVersion": "v1", "resources": []any{
+			map[string]any{"name": "pods", "singularName": "pod", "namespaced": true, "kind": "Pod", "verbs": []string{"list"}, "shortNames": []string{"po"}},
+		}})
+	})
+	mux.HandleFunc("/api/v1/namespaces/team/pods", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"kind": "PodList", "apiVersion": "v1", "metadata": map[string]any{}, "items": []any{pod("team", "a")}})
+	})
+	// All namespaces, paginated
+	mux.HandleFunc("/api/v1/pods", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("continue") == "" {
+			writeJSON(w, map[string]any{"kind": "PodList", "apiVersion": "v1", "metadata": map[string]any{"continue": "next"}, "items": []any{pod("team", "a")}})
+			return
+		}
+		writeJSON(w, map[string]any{"kind": "PodList", "apiVersion": "v1", "metadata": map[string]any{}, "items": []any{pod("other", "b")}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: team
+users:
+- name: test
+  user: {}
+`, server.URL)), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	tests := []struct {
+		name          string
+		resource      string
+		allNamespaces bool
+		want          []string
+	}{
+		{"current namespace", "pods", false, []string{"a"}},
+		{"short name", "po", false, []string{"a"}},
+		{"all namespaces", "pods", true, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := k8sList(context.Background(), tt.resource, tt.allNamespaces)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			items := []struct {
+				Metadata struct{ Name string }
+			}{}
+			err = json.Unmarshal(out, &items)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(items) != len(tt.want) {
+				t.Fatalf("got %s, want %v", out, tt.want)
+			}
+			for i, item := range items {
+				if item.Metadata.Name != tt.want[i] {
+					t.Errorf("got %s, want %v", out, tt.want)
+				}
+			}
+		})
+	}
+
+	_, err = k8sList(context.Background(), "nodes", false)
+	if err == nil {
+		t.Errorf("expected error for unknown resource")
+	}
+
+	names, err := k8sResources()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Equal(names, []string{"pods"}) {
+		t.Errorf("got %v, want [pods]", names)
+	}
+	got := argCompletions([]CommandArg{{Name: "resource", CompletionType: "k8s-resources"}}, nil, map[string]string{})
+	if !slices.Equal(got, []string{"pods"}) {
+		t.Errorf("got %v, want [pods]", got)
+	}
+}
+
+func TestNewRestConfigKubeconfigList(t *testing.T) {
+	dir := t.TempDir()
+	// The current context and the cluster are in different files, like with kubie
+	current := filepath.Join(dir, "current")
+	err := os.WriteFile(current, []byte("apiVersion: v1\nkind: Config\ncurrent-context: test\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	clusters := filepath.Join(dir, "clusters")
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://example.com:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    namespace: team
+users: []
+`
+	err = os.WriteFile(clusters, []byte(kubeconfig), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Setenv("KUBECONFIG", current+string(filepath.ListSeparator)+clusters)
+
+	config, namespace, err := NewRestConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if config.Host != "https://example.com:6443" || namespace != "team" {
+		t.Errorf("got %s %s, want https://example.com:6443 team", config.Host, namespace)
+	}
+
+	contextName, namespace, err := GetK8sContext(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if contextName != "test" || namespace != "team" {
+		t.Errorf("got %s %s, want test team", contextName, namespace)
+	}
+}
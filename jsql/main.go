@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"regexp"
+	"slices"
 	"strings"
 	"syscall"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/DavidGamba/dgtools/jsql/repl"
 	"github.com/DavidGamba/go-getoptions"
 	_ "github.com/duckdb/duckdb-go/v2"
+	"github.com/mattn/go-isatty"
 	"github.com/nyaosorg/go-readline-ny"
 )
 
@@ -30,12 +32,36 @@ func main() {
 func program(args []string) int {
 	opt := getoptions.New()
 	opt.Bool("quiet", false)
+	opt.String("db", "", opt.ArgName("file"), opt.GetEnv("JSQL_DB"), opt.Description("DB file to use, defaults to the config db or jsql.duckdb in the current dir"))
 	opt.SetUnknownMode(getoptions.Pass)
 	get := opt.NewCommand("get", "Run provider's Get command to retrieve data").SetCommandFn(GetRun)
 	get.HelpSynopsisArg("<provider_name>", "provider to use")
-	get.HelpSynopsisArg("<args>...", "provider arguments")
-
-	opt.NewCommand("query", "Run query's on the DB present in the CWD").SetCommandFn(QueryRun)
+	get.HelpSynopsisArg("<command>", "provider command")
+	get.HelpSynopsisArg("<args>...", "command arguments, positional or as --name value")
+	get.Bool("refresh", false, opt.Description("fetch the data even if the cached data is fresh"))
+	get.ArgCompletionsFns(GetCompletions)
+
+	cache := opt.NewCommand("cache", "Manage the cached provider data")
+	cacheList := cache.NewCommand("list", "List the cached data with its age and size").SetCommandFn(CacheListRun)
+	cacheList.HelpSynopsisArg("[<provider_name>]", "only list the data for the provider")
+	cacheClear := cache.NewCommand("clear", "Remove the cached data").SetCommandFn(CacheClearRun)
+	cacheClear.HelpSynopsisArg("[<provider_name>]", "only remove the data for the provider")
+	cacheClear.HelpSynopsisArg("[<command>]", "only remove the data for the provider command")
+
+	view := opt.NewCommand("view", "Fetch the provider data a view depends on and create the view").SetCommandFn(ViewRun)
+	view.HelpSynopsisArg("<provider_name>", "provider to use")
+	view.HelpSynopsisArg("<view_name>", "view to create")
+
+	query := opt.NewCommand("query", "Run queries on the DB").SetCommandFn(QueryRun)
+	query.String("execute", "", opt.Alias("e"), opt.ArgName("sql"), opt.Description("run the given SQL and exit"))
+	query.String("file", "", opt.Alias("f"), opt.ArgName("file.sql"), opt.Description("run the SQL in the file and exit, use - to read from stdin"))
+	query.String("run", "", opt.ArgName("name"), opt.Description("run the named query from the config and exit, the args are the query params"))
+	query.HelpSynopsisArg("[<params>...]", "params for the named query, key=value for $key or positional for $1, $2, ...")
+	query.String("mode", string(outputModePretty), opt.ValidValues(string(outputModePretty), string(outputModeSingleLine), string(outputModeTable), string(outputModeCSV), string(outputModeMarkdown), string(outputModeVertical)), opt.Description("output mode"))
+	query.String("output", "", opt.ArgName("file"), opt.Description("write the results to the file instead of stdout"))
+
+	queries := opt.NewCommand("queries", "Manage the saved queries")
+	queries.NewCommand("list", "List the saved queries from the config and the ones saved with .save").SetCommandFn(QueriesListRun)
 
 	opt.NewCommand("config", "Show parsed config").SetCommandFn(ConfigRun)
 
@@ -74,6 +100,8 @@ const (
 	outputModeSingleLine outputMode = "single_line"
 	outputModeTable      outputMode = "table"
 	outputModeCSV        outputMode = "csv"
+	outputModeMarkdown   outputMode = "markdown"
+	outputModeVertical   outputMode = "vertical"
 )
 
 type queryOption int
@@ -92,21 +120,49 @@ func QueryRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error
 		return fmt.Errorf("failed to read config: %w", err)
 	}
 
-	mode := outputModePretty
+	mode := outputMode(opt.Value("mode").(string))
 	qo := queryOptionAutoNumber
 
-	conn, err := dbConn(ctx)
+	conn, err := dbConn(ctx, opt)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
+	writer := os.Stdout
+	if opt.Value("output").(string) != "" {
+		fh, err := os.Create(opt.Value("output").(string))
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer fh.Close()
+		writer = fh
+	}
+
+	// Run non-interactively when given the SQL or when stdin is not a terminal
+	execute, file, name := opt.Value("execute").(string), opt.Value("file").(string), opt.Value("run").(string)
+	if execute != "" || file != "" || name != "" || !isatty.IsTerminal(os.Stdin.Fd()) {
+		query, err := querySource(d, execute, file, name, args)
+		if err != nil {
+			return err
+		}
+		return QueryExec(ctx, writer, conn, mode, queryOptionClear, query)
+	}
+
 	history, err := repl.NewHistoryFile("jsql", "history")
 	if err != nil {
 		return fmt.Errorf("failed to create history file: %w", err)
 	}
 
-	r := repl.New(history, completionCandidates)
+	completer := &Completer{}
+	for name := range d.Queries {
+		completer.queries = append(completer.queries, name)
+	}
+	err = completer.Refresh(ctx, conn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to load completion: %v\n", err)
+	}
+	r := repl.New(history, completer.Candidates)
 	r.SubmitOnEnterWhenEndsOn(";")
 
 	r.Ed.Highlight = append(r.Ed.Highlight, readline.Highlight{
@@ -117,8 +173,8 @@ func QueryRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error
 	r.IgnoreSIGINT = true
 	signal.Ignore(syscall.SIGINT)
 
-	writer := os.Stdout
-
+	lastQuery := ""     // last successful single SELECT-like query, re-executed by .export
+	lastSubmitted := "" // last query submitted, saved with .save even if it has params to substitute
 	for lines, err := range repl.Interactive(ctx, r) {
 		if err != nil {
 			return fmt.Errorf("%s", err)
@@ -135,8 +191,19 @@ func QueryRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error
 		if strings.HasPrefix(lines[0], ".help") {
 			fmt.Printf("%s\n", repl.DefaultHeader())
 			fmt.Printf(`
-.mode <pretty|single_line|table|csv>    - set output mode
+.get [--refresh] <provider> <command> [args...]
+                                        - run provider's Get command to refresh a table
+.view <provider> <view>                 - fetch the view dependencies and create the view
+.mode <pretty|single_line|table|csv|markdown|vertical>
+                                        - set output mode
 .output <stdout|file <filename>>        - set output target
+.export <file.parquet|.jsonl|.csv>      - re-execute the last single SELECT query and write its results to a file
+.save <name>                            - save the last query
+.run <name> [params...]                 - run a saved query, params are key=value for $key or positional for $1, $2, ...
+.tables                                 - list the tables and views
+.schema [table]                         - show the CREATE statement of the table, all tables if not given
+.attach <file> [as <name>]              - attach another DB file
+.detach <name>                          - detach a DB attached with .attach
 .option clear                           - clear all options
 .option autonumber                      - add a numbering column
 .help                                   - show this message
@@ -185,17 +252,152 @@ file <filename>: save to file
 				mode = outputModeTable
 			case regexp.MustCompile(`(?s)(?i)\.mode\s+csv`).MatchString(query):
 				mode = outputModeCSV
+			case regexp.MustCompile(`(?s)(?i)\.mode\s+markdown`).MatchString(query):
+				mode = outputModeMarkdown
+			case regexp.MustCompile(`(?s)(?i)\.mode\s+vertical`).MatchString(query):
+				mode = outputModeVertical
 			default:
 				fmt.Printf(`Valid modes:
 
 pretty: (default) json marshal results
 table: pretty print tables and json marshal nested data
 single_line: json marshal into one record per line
+csv: comma separated values
+markdown: markdown table
+vertical: one column per line for each record
 `)
 			}
 			continue
 		}
 
+		if strings.HasPrefix(lines[0], ".export") {
+			exportRegex := regexp.MustCompile(`(?s)(?i)\.export\s+(.+?)\s*;`)
+			matches := exportRegex.FindStringSubmatch(query)
+			switch {
+			case len(matches) < 2:
+				fmt.Printf("Usage: .export <file.parquet|file.jsonl|file.csv>;\n")
+			case lastQuery == "":
+				fmt.Printf("Error: no SELECT query to export\n")
+			default:
+				err := exportQuery(ctx, conn, lastQuery, matches[1])
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+				}
+			}
+			continue
+		}
+
+		if strings.HasPrefix(lines[0], ".save") {
+			fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+			switch {
+			case len(fields) != 2 || !queryNameRe.MatchString(fields[1]):
+				fmt.Printf("Usage: .save <name>;\n")
+			case lastSubmitted == "":
+				fmt.Printf("Error: no query to save\n")
+			default:
+				name := fields[1]
+				saved, err := readSavedQueries()
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				if _, ok := saved[name]; !ok && d.Queries[name] != "" {
+					fmt.Printf("Error: query '%s' is defined in the config\n", name)
+					continue
+				}
+				err = saveQuery(name, lastSubmitted)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				if _, ok := d.Queries[name]; !ok {
+					completer.queries = append(completer.queries, name)
+				}
+				d.Queries[name] = lastSubmitted
+				fmt.Printf("Saved query '%s' to %s\n", name, savedQueriesFile())
+			}
+			continue
+		}
+
+		if strings.HasPrefix(lines[0], ".run") {
+			fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+			if len(fields) < 2 {
+				fmt.Printf("Usage: .run <name> [params...];\n")
+				continue
+			}
+			q, ok := d.Queries[fields[1]]
+			if !ok {
+				fmt.Printf("Error: query not found: %s\n", fields[1])
+				continue
+			}
+			q = queryParams(q, fields[2:])
+			err := QueryExec(ctx, writer, conn, mode, qo, q)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			if stmt, ok := exportableQuery(q); ok {
+				lastQuery = stmt
+			}
+			continue
+		}
+
+		if strings.HasPrefix(lines[0], ".tables") {
+			err := runQuery(ctx, writer, conn, mode, queryOptionClear, tablesQuery)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(lines[0], ".schema") {
+			fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+			name := ""
+			if len(fields) > 1 {
+				name = fields[1]
+			}
+			statements, err := tableSchema(ctx, conn, name)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			for _, stmt := range statements {
+				fmt.Fprintln(writer, stmt)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(lines[0], ".attach") || strings.HasPrefix(lines[0], ".detach") {
+			attachRegex := regexp.MustCompile(`(?s)(?i)^\.attach\s+(\S+?)(?:\s+as\s+(\S+?))?\s*;`)
+			detachRegex := regexp.MustCompile(`(?s)(?i)^\.detach\s+(\S+?)\s*;`)
+			var stmt string
+			switch {
+			case attachRegex.MatchString(query):
+				matches := attachRegex.FindStringSubmatch(query)
+				filename, err := dbPath(matches[1])
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				stmt = attachQuery(filename, matches[2])
+			case detachRegex.MatchString(query):
+				stmt = fmt.Sprintf(`DETACH "%s"`, strings.ReplaceAll(detachRegex.FindStringSubmatch(query)[1], `"`, `""`))
+			default:
+				fmt.Printf("Usage: .attach <file> [as <name>]; or .detach <name>;\n")
+				continue
+			}
+			err := execQuery(ctx, conn, stmt)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			err = completer.Refresh(ctx, conn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: failed to update completion: %v\n", err)
+			}
+			continue
+		}
+
 		if strings.HasPrefix(lines[0], ".option") {
 			switch {
 			case regexp.MustCompile(`(?s)(?i)\.option\s+autonumber`).MatchString(query):
@@ -212,36 +414,51 @@ autonumber: add a numbering column
 			continue
 		}
 
-		// if strings.HasPrefix(lines[0], ".kget") {
-		// 	resourceRegex := regexp.MustCompile(`(?s)(?i)\.kget\s+(.+)\s*;`)
-		// 	switch {
-		// 	case resourceRegex.MatchString(query):
-		// 		matches := resourceRegex.FindStringSubmatch(query)
-		// 		if len(matches) > 1 {
-		// 			resource := matches[1]
-		//
-		// 			contextName, namespace, err := GetK8sContext(ctx)
-		// 			if err != nil {
-		// 				return fmt.Errorf("failed to get k8s context: %w", err)
-		// 			}
-		// 			Logger.Printf("Current context: %s, namespace: %s", contextName, namespace)
-		//
-		// 			cacheDir, err := createCacheDir(contextName)
-		// 			if err != nil {
-		// 				return fmt.Errorf("failed to get cache dir: %w", err)
-		// 			}
-		// 			Logger.Printf("Using cache dir: %s", cacheDir)
-		//
-		// 		}
-		// 	default:
-		// 		fmt.Printf(`Usage: .kget <resource> `)
-		// 	}
-		// 	continue
-		// }
+		if strings.HasPrefix(lines[0], ".view") {
+			fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+			err := ViewExec(ctx, conn, fields[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			err = completer.Refresh(ctx, conn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: failed to update completion: %v\n", err)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(lines[0], ".get") {
+			fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+			refresh := slices.Contains(fields, "--refresh")
+			fields = slices.DeleteFunc(fields[1:], func(f string) bool { return f == "--refresh" })
+			table, err := GetExec(ctx, conn, fields, refresh)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			if table != "" {
+				err = completer.Refresh(ctx, conn)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: failed to update completion: %v\n", err)
+				}
+			}
+			continue
+		}
 
+		lastSubmitted = query
 		err = runQuery(ctx, writer, conn, mode, qo, query)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		if stmt, ok := exportableQuery(query); ok {
+			lastQuery = stmt
+		}
+		// Pick up tables and macros created by the query
+		err = completer.Refresh(ctx, conn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to update completion: %v\n", err)
 		}
 	}
 
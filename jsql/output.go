@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatValue - Returns the value as a single line string, nested data is json marshalled.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	// Print times and other values marshalled as JSON strings without quotes
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s
+	}
+	return string(b)
+}
+
+// writeMarkdown - Writes the results as a markdown table with the columns in the given order.
+func writeMarkdown(w io.Writer, columns []string, results []map[string]any) {
+	escape := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	fmt.Fprintf(w, "|")
+	for _, col := range columns {
+		fmt.Fprintf(w, " %s |", escape.Replace(col))
+	}
+	fmt.Fprintf(w, "\n|")
+	for range columns {
+		fmt.Fprintf(w, " --- |")
+	}
+	fmt.Fprintf(w, "\n")
+	for _, row := range results {
+		fmt.Fprintf(w, "|")
+		for _, col := range columns {
+			fmt.Fprintf(w, " %s |", escape.Replace(formatValue(row[col])))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// writeVertical - Writes one `column | value` line per column for each row, like psql expanded mode.
+func writeVertical(w io.Writer, columns []string, results []map[string]any) {
+	width := 0
+	for _, col := range columns {
+		width = max(width, utf8.RuneCountInString(col))
+	}
+	for i, row := range results {
+		fmt.Fprintf(w, "-[ RECORD %d ]%s\n", i+1, strings.Repeat("-", width))
+		for _, col := range columns {
+			fmt.Fprintf(w, "%-*s | %s\n", width, col, formatValue(row[col]))
+		}
+	}
+}
+
+// exportFormats - DuckDB COPY format options by file extension.
+var exportFormats = map[string]string{
+	".parquet": "FORMAT parquet",
+	".jsonl":   "FORMAT json",
+	".ndjson":  "FORMAT json",
+	".csv":     "FORMAT csv, HEADER true",
+}
+
+// exportQuery - Writes the query results to filename with DuckDB's COPY, the format is chosen by the file extension.
+func exportQuery(ctx context.Context, conn *sql.Conn, query, filename string) error {
+	format, ok := exportFormats[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return fmt.Errorf("unsupported export file extension '%s', use .parquet, .jsonl, .ndjson or .csv", filepath.Ext(filename))
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	copyQuery := fmt.Sprintf("COPY (%s) TO '%s' (%s);", query, strings.ReplaceAll(filename, "'", "''"), format)
+	return execQuery(ctx, conn, copyQuery)
+}
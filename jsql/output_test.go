@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputModes(t *testing.T) {
+	columns := []string{"id", "metadata", "note"}
+	results := []map[string]any{
+		{"id": int32(1), "metadata": map[string]any{"name": "a"}, "note": "a|b\nc"},
+		{"id": int32(2), "metadata": nil, "note": ""},
+	}
+
+	var b bytes.Buffer
+	writeMarkdown(&b, columns, results)
+	want := `| id | metadata | note |
+| --- | --- | --- |
+| 1 | {"name":"a"} | a\|b<br>c |
+| 2 | NULL |  |
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	writeVertical(&b, columns, results[:1])
+	want = `-[ RECORD 1 ]--------
+id       | 1
+metadata | {"name":"a"}
+note     | a|b
+c
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
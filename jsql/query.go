@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// querySource - Returns the SQL to run non-interactively from the query options, the config saved queries or stdin.
+// The params are substituted in the saved query.
+func querySource(d *Config, execute, file, name string, params []string) (string, error) {
+	switch {
+	case execute != "":
+		return execute, nil
+	case file == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return string(b), nil
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read query file: %w", err)
+		}
+		return string(b), nil
+	case name != "":
+		q, ok := d.Queries[name]
+		if !ok {
+			return "", fmt.Errorf("query not found in config: %s", name)
+		}
+		return queryParams(q, params), nil
+	default:
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return string(b), nil
+	}
+}
+
+// QueryExec - Runs the SQL statements in order and stops at the first error.
+func QueryExec(ctx context.Context, w io.Writer, conn *sql.Conn, mode outputMode, qo queryOption, query string) error {
+	for _, stmt := range splitStatements(query) {
+		Logger.Printf("Running query: %s\n", stmt)
+		err := runQuery(ctx, w, conn, mode, qo, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements - Splits SQL into statements on the semicolons outside of quotes and comments.
+// Statements without SQL, only comments or blanks, are dropped.
+func splitStatements(query string) []string {
+	statements := []string{}
+	var b strings.Builder
+	hasSQL := false
+	flush := func() {
+		if hasSQL {
+			statements = append(statements, strings.TrimSpace(b.String()))
+		}
+		b.Reset()
+		hasSQL = false
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := i + 1
+			for end < len(query) && query[end] != c {
+				end++
+			}
+			end = min(end+1, len(query))
+			b.WriteString(query[i:end])
+			i = end - 1
+			hasSQL = true
+		case strings.HasPrefix(query[i:], "--"):
+			end := len(query)
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				end = i + j
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := len(query)
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				end = i + 2 + j + 2
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == ';':
+			b.WriteByte(c)
+			flush()
+		default:
+			b.WriteByte(c)
+			if !isSpace(c) {
+				hasSQL = true
+			}
+		}
+	}
+	flush()
+	return statements
+}
+
+// Statements that return rows and can be re-executed by .export
+var selectLikeRe = regexp.MustCompile(`(?i)^(select|with|from|values|table|show|describe|summarize|pivot|unpivot)\b`)
+
+// exportableQuery - Returns the query if it is a single statement that only reads rows.
+// .export re-executes the query, so statements that modify the DB and multi statement input can't be exported.
+func exportableQuery(query string) (string, bool) {
+	stmts := splitStatements(query)
+	if len(stmts) != 1 {
+		return "", false
+	}
+	if !selectLikeRe.MatchString(stripLeadingComments(stmts[0])) {
+		return "", false
+	}
+	return stmts[0], true
+}
+
+// stripLeadingComments - Removes the comments, blanks and opening parens before the first keyword.
+func stripLeadingComments(stmt string) string {
+	for {
+		stmt = strings.TrimLeft(stmt, " \t\n\r(")
+		switch {
+		case strings.HasPrefix(stmt, "--"):
+			_, rest, ok := strings.Cut(stmt, "\n")
+			if !ok {
+				return ""
+			}
+			stmt = rest
+		case strings.HasPrefix(stmt, "/*"):
+			_, rest, ok := strings.Cut(stmt[2:], "*/")
+			if !ok {
+				return ""
+			}
+			stmt = rest
+		default:
+			return stmt
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
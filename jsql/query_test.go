@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"single", "SELECT 1", []string{"SELECT 1"}},
+		{"multiple", "SELECT 1;\nSELECT 2;\n", []string{"SELECT 1;", "SELECT 2;"}},
+		{"quotes", `SELECT 'a;b', "c;d";`, []string{`SELECT 'a;b', "c;d";`}},
+		{"escaped quote", `SELECT 'it''s;'; SELECT 2;`, []string{`SELECT 'it''s;';`, "SELECT 2;"}},
+		{"comments", "-- first; query\nSELECT 1; /* ; */ SELECT 2;\n-- done;", []string{"-- first; query\nSELECT 1;", "/* ; */ SELECT 2;"}},
+		{"blank", " ;\n; ", []string{}},
+		{"unterminated", "SELECT 'a;", []string{"SELECT 'a;"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.query)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportableQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		ok    bool
+	}{
+		{"select", "SELECT 1;", "SELECT 1;", true},
+		{"lowercase with", "with t as (select 1) select * from t", "with t as (select 1) select * from t", true},
+		{"comments", "-- count\n/* all */ (SELECT 1);", "-- count\n/* all */ (SELECT 1);", true},
+		{"create", "CREATE TABLE t AS SELECT 1;", "", false},
+		{"insert", "INSERT INTO t VALUES (1);", "", false},
+		{"multiple", "CREATE TABLE t AS SELECT 1; SELECT * FROM t;", "", false},
+		{"selection prefix", "selection;", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := exportableQuery(tt.query)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %q %v, want %q %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -30,40 +30,43 @@ func (OSClipboard) Write(s string) error {
 
 type HistoryFile struct {
 	filename string
+	lines    []string // cached entries, loaded on first use
+	loaded   bool
 }
 
-func (h *HistoryFile) At(n int) string {
+// load - Reads the history entries once so that At and Len, called for every step of a history search, don't read the file.
+func (h *HistoryFile) load() {
+	if h.loaded {
+		return
+	}
+	h.loaded = true
 	f, err := os.Open(h.filename)
 	if err != nil {
-		return ""
+		return
 	}
 	defer f.Close()
 	scanner := bufio.NewScanner(f)
-	i := 0
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		if i == n {
-			return strings.ReplaceAll(scanner.Text(), "⏎", "\n")
-		}
-		i++
+		h.lines = append(h.lines, strings.ReplaceAll(scanner.Text(), "⏎", "\n"))
 	}
-	return ""
 }
 
-func (h *HistoryFile) Len() int {
-	f, err := os.Open(h.filename)
-	if err != nil {
-		return 0
-	}
-	defer f.Close()
-	scanner := bufio.NewScanner(f)
-	count := 0
-	for scanner.Scan() {
-		count++
+func (h *HistoryFile) At(n int) string {
+	h.load()
+	if n < 0 || n >= len(h.lines) {
+		return ""
 	}
-	return count
+	return h.lines[n]
+}
+
+func (h *HistoryFile) Len() int {
+	h.load()
+	return len(h.lines)
 }
 
 func (h *HistoryFile) Add(line string) error {
+	h.load()
 	f, err := os.OpenFile(h.filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open history file: %w", err)
@@ -73,6 +76,7 @@ func (h *HistoryFile) Add(line string) error {
 	if err != nil {
 		return fmt.Errorf("failed to write to history file: %w", err)
 	}
+	h.lines = append(h.lines, strings.ReplaceAll(line, "⏎", "\n"))
 	return nil
 }
 
@@ -87,7 +91,7 @@ func NewHistoryFile(dir, name string) (*HistoryFile, error) {
 	cacheDir := filepath.Join(cacheDirBase, dir)
 	os.MkdirAll(cacheDir, 0755)
 	historyFile := filepath.Join(cacheDir, name)
-	return &HistoryFile{historyFile}, nil
+	return &HistoryFile{filename: historyFile}, nil
 }
 
 func DefaultHeader() string {
@@ -100,6 +104,7 @@ func DefaultHeader() string {
 	C-D with no chars : Quit.
 	C-UP   or Meta-P  : Move to the previous history entry
 	C-DOWN or Meta-N  : Move to the next history entry
+	C-r               : Search the history backwards
 `
 }
 
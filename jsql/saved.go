@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/DavidGamba/dgtools/clitable"
+	"github.com/DavidGamba/go-getoptions"
+)
+
+// savedQueriesFile - Queries saved with .save, next to the global config.
+func savedQueriesFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "jsql", "saved_queries.json")
+}
+
+func readSavedQueries() (map[string]string, error) {
+	queries := map[string]string{}
+	b, err := os.ReadFile(savedQueriesFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return queries, nil
+		}
+		return nil, fmt.Errorf("failed to read saved queries: %w", err)
+	}
+	err = json.Unmarshal(b, &queries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse saved queries: %w", err)
+	}
+	return queries, nil
+}
+
+// saveQuery - Saves the query under name, replacing a previously saved query with the same name.
+func saveQuery(name, query string) error {
+	queries, err := readSavedQueries()
+	if err != nil {
+		return err
+	}
+	queries[name] = query
+	b, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved queries: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(savedQueriesFile()), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	err = os.WriteFile(savedQueriesFile(), b, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write saved queries: %w", err)
+	}
+	return nil
+}
+
+var queryNameRe = regexp.MustCompile(`^[\w.-]+$`)
+
+// queryParams - Replaces $name placeholders with the key=value params and $1, $2, ... with the positional params.
+func queryParams(query string, params []string) string {
+	replacements := map[string]string{}
+	i := 1
+	for _, p := range params {
+		if k, v, ok := strings.Cut(p, "="); ok && queryNameRe.MatchString(k) {
+			replacements[k] = v
+			continue
+		}
+		replacements[fmt.Sprintf("%d", i)] = p
+		i++
+	}
+	return replacePlaceholders(query, replacements)
+}
+
+func QueriesListRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+	d := &Config{}
+	err := ReadConfig(d, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if len(d.Queries) == 0 {
+		fmt.Fprintf(os.Stderr, "No saved queries\n")
+		return nil
+	}
+	names := make([]string, 0, len(d.Queries))
+	for name := range d.Queries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	data := [][]string{{"Name", "Query"}}
+	for _, name := range names {
+		data = append(data, []string{name, strings.TrimSpace(d.Queries[name])})
+	}
+	return clitable.NewTablePrinter().Print(clitable.SimpleTable{Data: data})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQueryParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		params []string
+		want   string
+	}{
+		{"none", "SELECT 1;", nil, "SELECT 1;"},
+		{"named", "SELECT * FROM t WHERE ns = '$ns' AND n = $n;", []string{"ns=default", "n=3"}, "SELECT * FROM t WHERE ns = 'default' AND n = 3;"},
+		{"positional", "SELECT $1, $2;", []string{"a", "b"}, "SELECT a, b;"},
+		{"longest first", "SELECT $1, $10;", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, "SELECT a, j;"},
+		{"mixed", "SELECT $1, '$name';", []string{"name=x", "a"}, "SELECT a, 'x';"},
+		{"value with equals", "SELECT '$1';", []string{"a b=c"}, "SELECT 'a b=c';"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := queryParams(tt.query, tt.params)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveQuery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	err := saveQuery("a", "SELECT 1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = saveQuery("b", "SELECT $1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = saveQuery("a", "SELECT 2;")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	queries, err := readSavedQueries()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(queries) != 2 || queries["a"] != "SELECT 2;" || queries["b"] != "SELECT $1;" {
+		t.Errorf("unexpected saved queries: %v", queries)
+	}
+}
@@ -7,24 +7,43 @@ package jsql
 	getCommands: [string]: #GetCommand
 	macros: [...string]
 	views: [string]: {
+		// get commands with their args, e.g. "getAll pods"
 		dependencies: [...string]
 		queries:      [...string]
 	}
 }
 
 #GetCommand: {
-	name:    string
+	name: string
+	// command runs the command, k8s lists the resource with the current kube context
+	type:    *"command" | "k8s"
 	table:   string
 	command: [...string]
 	filter:  [...string]
 	create:  [...string]
+	// How long the fetched data is reused for, e.g. "10m" or "1h", empty to always fetch
+	ttl:     string | *""
 	args:    [...#CommandArg]
+	if type == "k8s" {
+		// resource name, short name or resource.group, e.g. "pods", "deploy" or "$resource"
+		resource:      string
+		allNamespaces: bool | *false
+	}
 	...
 }
 
+// Available in the command as $name and $arg1, $arg2, ...
+// Passed positionally or as --name value
 #CommandArg: {
 	name:        string
 	description: string | *""
+	// Makes the arg optional
+	default?: string
+	validValues: [...string]
+	// Command that prints the valid values, one per line, for shell completion, e.g. ["kubectl", "get", "ns", "-o", "name"]
+	completion: [...string]
+	// Built-in completion instead of a command, k8s-resources lists the resources of the current kube context
+	completionType?: "k8s-resources"
 }
 
 provider: [string]~(X,_): #Provider & {
@@ -35,3 +54,9 @@ provider: [string]~(X,_): #Provider & {
 		table: string | *Y
 	}
 }
+
+// Saved queries that can be run by name
+queries: [string]: string
+
+// DB file, relative paths are relative to the current dir, e.g. ".jsql/k8s.duckdb"
+db: string | *""
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func ViewRun(ctx context.Context, opt *getoptions.GetOpt, args []string) error {
+	Logger.Printf("Running")
+
+	conn, err := dbConn(ctx, opt)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return ViewExec(ctx, conn, args)
+}
+
+// ViewExec - Fetches the data for the view dependencies and runs the view queries.
+// Each dependency is a provider get command with its args, e.g. "getAll pods", cached data is reused while fresh.
+func ViewExec(ctx context.Context, conn *sql.Conn, args []string) error {
+	d := &Config{}
+	err := ReadConfig(d, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	// If no arguments are provided list all providers with views
+	if len(args) < 1 {
+		fmt.Printf("Valid provider list: \n")
+		for k, p := range d.Provider {
+			if len(p.Views) > 0 {
+				fmt.Printf("%s\n", k)
+			}
+		}
+		return nil
+	}
+
+	// get and validate provider
+	provider, args := args[0], args[1:]
+	if _, ok := d.Provider[provider]; !ok {
+		return fmt.Errorf("invalid provider: %s", provider)
+	}
+
+	// If no arguments are provided list all views for the provider
+	if len(args) < 1 {
+		fmt.Printf("Valid views for provider %s: \n", provider)
+		for k := range d.Provider[provider].Views {
+			fmt.Printf("%s\n", k)
+		}
+		return nil
+	}
+
+	// get and validate view
+	name := args[0]
+	view, ok := d.Provider[provider].Views[name]
+	if !ok {
+		return fmt.Errorf("invalid view: %s", name)
+	}
+
+	for _, dep := range view.Dependencies {
+		Logger.Printf("View %s depends on: %s %s", name, provider, dep)
+		_, err := GetExec(ctx, conn, append([]string{provider}, strings.Fields(dep)...), false)
+		if err != nil {
+			return fmt.Errorf("failed to get view dependency '%s': %w", dep, err)
+		}
+	}
+
+	for _, e := range view.Queries {
+		e = strings.ReplaceAll(e, "$schemaName", d.Provider[provider].SchemaName)
+		e = strings.ReplaceAll(e, "$provider", provider)
+		err = execQuery(ctx, conn, e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LineFn - Receives a line of output without the trailing newline.
+type LineFn func(line string)
+
+// LineWriter - io.Writer that calls a LineFn for every complete line written to it.
+//
+// Call Flush after the last write to deliver a trailing line that doesn't end in a newline.
+type LineWriter struct {
+	fn  LineFn
+	mu  *sync.Mutex
+	buf []byte
+}
+
+// NewLineWriter - Returns a LineWriter that calls fn for every line.
+func NewLineWriter(fn LineFn) *LineWriter {
+	return &LineWriter{fn: fn, mu: &sync.Mutex{}}
+}
+
+// NewPrefixWriter - Returns a LineWriter that writes every line to w with the given prefix.
+//
+//	w := run.NewPrefixWriter(os.Stdout, "[vpc:dev] ")
+//	defer w.Flush()
+func NewPrefixWriter(w io.Writer, prefix string) *LineWriter {
+	return NewLineWriter(func(line string) {
+		fmt.Fprintf(w, "%s%s\n", prefix, line)
+	})
+}
+
+func (lw *LineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.fn(string(bytes.TrimSuffix(lw.buf[:i], []byte("\r"))))
+		lw.buf = lw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush - Delivers any remaining partial line.
+func (lw *LineWriter) Flush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if len(lw.buf) == 0 {
+		return
+	}
+	lw.fn(string(lw.buf))
+	lw.buf = nil
+}
@@ -0,0 +1,126 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaskReplacement - Replaces the masked values.
+const MaskReplacement = "*****"
+
+// Mask - Replace the given secret values with MaskReplacement in the logged command,
+// in the commands reported in errors and results, and in recordings.
+//
+//	err := run.CMD("terraform", "init", "-backend-config=token="+token).Mask(token).Log().Run()
+//	// run [terraform init -backend-config=token=*****]
+//
+// Use MaskOutput to mask the command output as well.
+func (r *RunInfo) Mask(values ...string) *RunInfo {
+	for _, v := range values {
+		if v != "" {
+			r.maskValues = append(r.maskValues, v)
+		}
+	}
+	return r
+}
+
+// MaskPattern - Like Mask, but masks the matches of the given regexes.
+// Args are matched individually.
+// If the regex has capture groups only the groups are masked, otherwise the whole match is masked.
+//
+//	run.CMD(...).MaskPattern(regexp.MustCompile(`password=(.*)`))
+//	// password=*****
+func (r *RunInfo) MaskPattern(patterns ...*regexp.Regexp) *RunInfo {
+	r.maskPatterns = append(r.maskPatterns, patterns...)
+	return r
+}
+
+// MaskOutput - Apply the masks to the command stdout and stderr output, both when forwarded and when captured.
+//
+// Output is written line by line, a trailing line without a newline gets one added.
+// The error output saved with SaveErr is masked as well.
+func (r *RunInfo) MaskOutput() *RunInfo {
+	r.maskOutput = true
+	return r
+}
+
+// mask - Returns s with the masks applied.
+func (r *RunInfo) mask(s string) string {
+	for _, v := range r.maskValues {
+		s = strings.ReplaceAll(s, v, MaskReplacement)
+	}
+	for _, re := range r.maskPatterns {
+		s = maskMatches(re, s)
+	}
+	return s
+}
+
+// maskErr - Returns err with the masks applied to its message.
+// The original error is still available to errors.Is and errors.As.
+func (r *RunInfo) maskErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := r.mask(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &maskedError{msg: msg, err: err}
+}
+
+type maskedError struct {
+	msg string
+	err error
+}
+
+func (e *maskedError) Error() string { return e.msg }
+
+func (e *maskedError) Unwrap() error { return e.err }
+
+// maskedCmd - Returns the command args with the masks applied.
+func (r *RunInfo) maskedCmd() []string {
+	if len(r.maskValues) == 0 && len(r.maskPatterns) == 0 {
+		return r.Cmd
+	}
+	cmd := make([]string, len(r.Cmd))
+	for i, arg := range r.Cmd {
+		cmd[i] = r.mask(arg)
+	}
+	return cmd
+}
+
+// maskMatches - Replaces the capture groups of the matches of re in s, or the whole match if re has no groups.
+func maskMatches(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		spans := m[:2]
+		if len(m) > 2 {
+			spans = m[2:]
+		}
+		for i := 0; i < len(spans); i += 2 {
+			start, end := spans[i], spans[i+1]
+			// Skip groups that didn't participate and groups nested in an already masked one
+			if start < 0 || start < last || start == end {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(MaskReplacement)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
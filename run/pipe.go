@@ -0,0 +1,206 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// PipeInfo - Pipeline of commands where the stdout of each command is connected to the stdin of the next.
+type PipeInfo struct {
+	Cmds   []*RunInfo // exposed for mocking purposes only
+	Stdout io.Writer  // exposed for mocking purposes only
+	Stderr io.Writer  // exposed for mocking purposes only
+	debug  bool
+	dryRun bool
+	ctx    context.Context
+}
+
+// Pipe - Connects the given commands into a pipeline, the equivalent of `a | b | c` in a shell.
+//
+//	out, err := run.Pipe(
+//		run.CMD("kubectl", "get", "pods", "-o", "name"),
+//		run.CMD("grep", "web"),
+//	).STDOutOutput()
+//
+// Each command keeps its own options, e.g. Dir, Env or Mock.
+// The stdin of the first command and the stderr of every command are set as usual.
+func Pipe(cmds ...*RunInfo) *PipeInfo {
+	return &PipeInfo{Cmds: cmds}
+}
+
+// Log - Log the pipeline before running it.
+func (p *PipeInfo) Log() *PipeInfo {
+	p.debug = true
+	return p
+}
+
+// DryRun - Set dry run on every command of the pipeline.
+func (p *PipeInfo) DryRun(b bool) *PipeInfo {
+	p.dryRun = b
+	return p
+}
+
+// DiscardErr - Don't print the error output of the commands to stderr by default.
+func (p *PipeInfo) DiscardErr() *PipeInfo {
+	for _, r := range p.Cmds {
+		r.DiscardErr()
+	}
+	return p
+}
+
+// Ctx - Set the context of every command of the pipeline.
+// Cancelling it stops all the commands.
+func (p *PipeInfo) Ctx(ctx context.Context) *PipeInfo {
+	p.ctx = ctx
+	return p
+}
+
+// CombinedOutput - Runs the pipeline and returns the STDOut of the last command combined with the STDErr of every command.
+func (p *PipeInfo) CombinedOutput() ([]byte, error) {
+	var b bytes.Buffer
+	p.Stdout = &b
+	p.Stderr = &b
+	err := p.Run()
+	return b.Bytes(), err
+}
+
+// STDOutOutput - Runs the pipeline and returns the STDOut of the last command.
+func (p *PipeInfo) STDOutOutput() ([]byte, error) {
+	var b bytes.Buffer
+	p.Stdout = &b
+	err := p.Run()
+	return b.Bytes(), err
+}
+
+// String - Returns the pipeline in shell like notation.
+func (p *PipeInfo) String() string {
+	cmds := []string{}
+	for _, r := range p.Cmds {
+		cmds = append(cmds, fmt.Sprintf("%v", r.maskedCmd()))
+	}
+	return strings.Join(cmds, " | ")
+}
+
+// Run - Runs all the commands of the pipeline concurrently and waits for them to complete.
+//
+// The writers are used the same way as in RunInfo.Run, Stdout is the output of the last command and
+// Stderr is shared by all the commands.
+//
+// The returned error is a *PipeError for the first command that failed.
+// A command that stopped because a later command exited without reading all of its input,
+// e.g. `yes | head -1`, is not considered a failure.
+func (p *PipeInfo) Run(w ...io.Writer) error {
+	if len(p.Cmds) == 0 {
+		return fmt.Errorf("empty pipeline")
+	}
+	if p.debug {
+		msg := ""
+		if p.dryRun {
+			msg += "DRY-RUN "
+		}
+		msg += "run " + p.String()
+		Logger.Println(msg)
+	}
+	if len(w) == 1 {
+		p.Stdout = w[0]
+		p.Stderr = w[0]
+	} else if len(w) > 1 {
+		p.Stdout = w[0]
+		p.Stderr = w[1]
+	}
+	// The commands run concurrently, serialize the writes to the shared writers
+	stdout := p.Stdout
+	stderr := p.Stderr
+	if stdout != nil && sameWriter(stdout, stderr) {
+		stdout = &syncWriter{w: stdout}
+		stderr = stdout
+	} else if stderr != nil {
+		stderr = &syncWriter{w: stderr}
+	}
+
+	for i, r := range p.Cmds {
+		if p.ctx != nil {
+			r.ctx = p.ctx
+		}
+		if p.dryRun {
+			r.dryRun = true
+		}
+		if stderr != nil {
+			r.Stderr = stderr
+		}
+		if i == len(p.Cmds)-1 && stdout != nil {
+			r.Stdout = stdout
+		}
+	}
+	writers := make([]*io.PipeWriter, len(p.Cmds))
+	readers := make([]*io.PipeReader, len(p.Cmds))
+	for i := 0; i < len(p.Cmds)-1; i++ {
+		readers[i+1], writers[i] = io.Pipe()
+		p.Cmds[i].Stdout = writers[i]
+		p.Cmds[i+1].stdin = readers[i+1]
+	}
+
+	errs := make([]error, len(p.Cmds))
+	var wg sync.WaitGroup
+	for i, r := range p.Cmds {
+		wg.Add(1)
+		go func(i int, r *RunInfo) {
+			defer wg.Done()
+			errs[i] = r.Run()
+			// Signal EOF to the next command and unblock the previous one if this one stopped reading early
+			if writers[i] != nil {
+				writers[i].Close()
+			}
+			if readers[i] != nil {
+				readers[i].Close()
+			}
+		}(i, r)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil || (i < len(p.Cmds)-1 && brokenPipe(err)) {
+			continue
+		}
+		return &PipeError{Stage: i, Cmd: p.Cmds[i].maskedCmd(), Err: err}
+	}
+	return nil
+}
+
+// PipeError - Error of the failed command of a pipeline.
+type PipeError struct {
+	Stage int      // index of the failed command in the pipeline
+	Cmd   []string // failed command, with the command masks applied
+	Err   error
+}
+
+func (e *PipeError) Error() string {
+	return fmt.Sprintf("pipeline command %d %v failed: %s", e.Stage, e.Cmd, e.Err)
+}
+
+func (e *PipeError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode - Returns the exit code of the failed command or -1 if it didn't exit normally.
+func (e *PipeError) ExitCode() int {
+	return ExitCode(e.Err)
+}
+
+// brokenPipe - The command stopped because the next command in the pipeline stopped reading.
+func brokenPipe(err error) bool {
+	return errors.Is(err, io.ErrClosedPipe) || signaledBrokenPipe(err)
+}
@@ -0,0 +1,35 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package run
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup - Process groups are not supported.
+func setProcessGroup(c *exec.Cmd) {}
+
+// signalProcess - Sends the signal to the process, process groups are not supported.
+func signalProcess(c *exec.Cmd, sig os.Signal, group bool) error {
+	return c.Process.Signal(sig)
+}
+
+// signaledBrokenPipe - There is no SIGPIPE, a failed write to a closed pipe is reported as a regular error.
+func signaledBrokenPipe(err error) bool {
+	return false
+}
+
+// maxRSS - Not available.
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,70 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package run
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+func setProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Setpgid = true
+}
+
+// signalProcess - Sends the signal to the process or to its process group.
+func signalProcess(c *exec.Cmd, sig os.Signal, group bool) error {
+	if !group {
+		return c.Process.Signal(sig)
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return c.Process.Signal(sig)
+	}
+	err := syscall.Kill(-c.Process.Pid, s)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// signaledBrokenPipe - The command failed writing to a closed pipe.
+func signaledBrokenPipe(err error) bool {
+	if errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return ws.Signaled() && ws.Signal() == syscall.SIGPIPE
+		}
+	}
+	return false
+}
+
+// maxRSS - Returns the max resident set size of the process in bytes.
+func maxRSS(ps *os.ProcessState) int64 {
+	rusage, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// Reported in bytes on macOS and in kilobytes elsewhere
+	if runtime.GOOS == "darwin" {
+		return int64(rusage.Maxrss)
+	}
+	return int64(rusage.Maxrss) * 1024
+}
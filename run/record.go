@@ -0,0 +1,259 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// RecordEnvVar - When set to true, RecordReplay runs the commands and records them instead of replaying them.
+const RecordEnvVar = "RUN_RECORD"
+
+// ErrUnexpectedCommand - Returned in replay mode by commands without a matching recording.
+var ErrUnexpectedCommand = errors.New("unexpected command")
+
+// Recording - Captured execution of a command.
+type Recording struct {
+	Cmd      []string `json:"cmd"`
+	Env      []string `json:"env,omitempty"` // key=value pairs added to the caller's environment
+	Dir      string   `json:"dir,omitempty"`
+	Stdin    string   `json:"stdin,omitempty"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+	Error    string   `json:"error,omitempty"` // error of a command that didn't exit normally, e.g. not found
+}
+
+// TB - The subset of testing.TB used by RecordReplay.
+type TB interface {
+	Helper()
+	Cleanup(func())
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+type recorder struct {
+	t          TB
+	file       string
+	mu         sync.Mutex
+	recordings []Recording
+	used       []bool
+}
+
+// RecordReplay - Returns a mock function that replays the commands recorded in the given golden file.
+//
+// When the RUN_RECORD env var is set to true, the commands are run and recorded into the file instead.
+// The file is written when the test completes.
+//
+//	mock := run.CMDCtx(ctx).Mock(run.RecordReplay(t, "testdata/plan.json"))
+//	ctx = run.ContextWithRunInfo(ctx, mock)
+//
+// Commands are matched by their args, dir, added env vars and stdin, in the order they were recorded.
+// The dir is matched as given, so prefer relative dirs over temporary ones that change between runs.
+// Values masked with Mask or MaskPattern are stored masked and matched after masking.
+// Commands without a matching recording and recordings that are not used fail the test.
+//
+// A non zero recorded exit code is replayed as an *ExitError, use ExitCode to get the exit code of either
+// the recorded or the replayed command.
+// Commands run with DryRun are neither run nor recorded, and they are not matched against the recordings.
+func RecordReplay(t TB, file string) MockFn {
+	t.Helper()
+	rec := &recorder{t: t, file: file}
+
+	if record, _ := strconv.ParseBool(os.Getenv(RecordEnvVar)); record {
+		t.Cleanup(func() {
+			err := rec.save()
+			if err != nil {
+				t.Errorf("failed to save recordings: %s", err)
+			}
+		})
+		return rec.record
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read recordings, run the tests with %s=true to record them: %s", RecordEnvVar, err)
+		return nil
+	}
+	err = json.Unmarshal(data, &rec.recordings)
+	if err != nil {
+		t.Fatalf("failed to parse recordings from %s: %s", file, err)
+		return nil
+	}
+	rec.used = make([]bool, len(rec.recordings))
+	t.Cleanup(func() {
+		for i, used := range rec.used {
+			if !used {
+				t.Errorf("recorded command not run: %v", rec.recordings[i].Cmd)
+			}
+		}
+	})
+	return rec.replay
+}
+
+func (rec *recorder) record(r *RunInfo) error {
+	if r.dryRun {
+		return nil
+	}
+	stdin, err := readStdin(r)
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	stdoutW, stderrW := r.Stdout, r.Stderr
+	if stdoutW != nil && sameWriter(stdoutW, stderrW) {
+		// The tee writers are different, serialize the writes to the shared writer
+		sw := &syncWriter{w: stdoutW}
+		stdoutW, stderrW = sw, sw
+	}
+	err = r.runCmd(teeWriter(stdoutW, &stdout), teeWriter(stderrW, &stderr), r.stdin)
+
+	// Recordings are usually committed, keep the secrets out
+	recording := Recording{
+		Cmd:    r.maskedCmd(),
+		Env:    recordedEnv(r),
+		Dir:    r.dir,
+		Stdin:  r.mask(string(stdin)),
+		Stdout: r.mask(stdout.String()),
+		Stderr: r.mask(stderr.String()),
+	}
+	if err != nil {
+		recording.ExitCode = ExitCode(err)
+		if recording.ExitCode == -1 {
+			recording.Error = err.Error()
+		}
+	}
+	rec.mu.Lock()
+	rec.recordings = append(rec.recordings, recording)
+	rec.mu.Unlock()
+	return err
+}
+
+func (rec *recorder) replay(r *RunInfo) error {
+	if r.dryRun {
+		return nil
+	}
+	stdin, err := readStdin(r)
+	if err != nil {
+		return err
+	}
+
+	cmd, env := r.maskedCmd(), recordedEnv(r)
+	rec.mu.Lock()
+	var recording *Recording
+	for i := range rec.recordings {
+		rc := rec.recordings[i]
+		if rec.used[i] || !equalStrings(rc.Cmd, cmd) || rc.Dir != r.dir || !equalStrings(rc.Env, env) || rc.Stdin != r.mask(string(stdin)) {
+			continue
+		}
+		rec.used[i] = true
+		recording = &rec.recordings[i]
+		break
+	}
+	rec.mu.Unlock()
+	if recording == nil {
+		rec.t.Errorf("%s: %v", ErrUnexpectedCommand, cmd)
+		return fmt.Errorf("%w: %v", ErrUnexpectedCommand, cmd)
+	}
+
+	if r.Stdout != nil {
+		r.Stdout.Write([]byte(recording.Stdout))
+	}
+	if r.Stderr != nil {
+		r.Stderr.Write([]byte(recording.Stderr))
+	}
+	if recording.Error != "" {
+		return errors.New(recording.Error)
+	}
+	if recording.ExitCode != 0 {
+		return &ExitError{Code: recording.ExitCode}
+	}
+	return nil
+}
+
+func (rec *recorder) save() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.recordings == nil {
+		rec.recordings = []Recording{}
+	}
+	data, err := json.MarshalIndent(rec.recordings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recordings: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(rec.file), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create dir: %w", err)
+	}
+	return os.WriteFile(rec.file, append(data, '\n'), 0644)
+}
+
+// readStdin - Reads the command stdin so it can be recorded or matched, and replaces it with a reader over the read data.
+func readStdin(r *RunInfo) ([]byte, error) {
+	if r.stdin == nil {
+		return nil, nil
+	}
+	stdin, err := io.ReadAll(r.stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	r.stdin = bytes.NewReader(stdin)
+	return stdin, nil
+}
+
+// recordedEnv - Returns the masked env vars added to the caller's environment, as stored in the recordings.
+func recordedEnv(r *RunInfo) []string {
+	env := []string{}
+	for _, e := range envDiff(r.env) {
+		env = append(env, r.mask(e))
+	}
+	return env
+}
+
+// envDiff - Returns the entries of env that are not part of the caller's environment.
+func envDiff(env []string) []string {
+	current := map[string]struct{}{}
+	for _, e := range os.Environ() {
+		current[e] = struct{}{}
+	}
+	diff := []string{}
+	for _, e := range env {
+		if _, ok := current[e]; !ok {
+			diff = append(diff, e)
+		}
+	}
+	return diff
+}
+
+func teeWriter(w io.Writer, b *bytes.Buffer) io.Writer {
+	if w == nil {
+		return b
+	}
+	return io.MultiWriter(w, b)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
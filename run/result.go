@@ -0,0 +1,98 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// ExitError - Non zero exit of a mocked command, e.g. of the commands replayed by RecordReplay.
+// Mock functions can return it to simulate a command that exits with the given exit code.
+// Like with *exec.ExitError, SaveErr saves the stderr output into Stderr.
+type ExitError struct {
+	Code   int
+	Stderr []byte
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode - Returns the exit code of the mocked command.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// ExitCode - Returns the exit code of the command that returned err, either an *exec.ExitError or an *ExitError.
+// Returns 0 if err is nil and -1 if the command didn't exit normally, e.g. it wasn't found or was killed by a signal.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var mockErr *ExitError
+	if errors.As(err, &mockErr) {
+		return mockErr.Code
+	}
+	return -1
+}
+
+// Result - Outcome of a command run with Exec.
+type Result struct {
+	Cmd      []string // with the command masks applied
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int // -1 if the command didn't exit normally, e.g. it wasn't found or was killed by a signal
+
+	Duration   time.Duration // wall time, including retries
+	UserTime   time.Duration // user CPU time of the last run
+	SystemTime time.Duration // system CPU time of the last run
+	MaxRSS     int64         // max resident set size of the last run in bytes, 0 if not available
+}
+
+// Exec - Runs given CMD and returns a Result with its output, exit code, timing and resource usage.
+//
+// The Result is returned even when the command fails.
+// Stderr output is also printed to os.Stderr unless a call to DiscardErr() was made.
+//
+//	res, err := run.CMD("terraform", "plan").Exec()
+//	log.Printf("%v took %s, cpu %s, max rss %d MB\n", res.Cmd, res.Duration, res.UserTime+res.SystemTime, res.MaxRSS>>20)
+//
+// Mocked commands only report the output, exit code and duration.
+func (r *RunInfo) Exec() (*Result, error) {
+	var stdout, stderr bytes.Buffer
+	r.Stdout = &stdout
+	r.Stderr = &stderr
+	r.output = &stdout
+	r.errOutput = &stderr
+	start := time.Now()
+	err := r.Run()
+
+	res := &Result{
+		Cmd:      r.maskedCmd(),
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Duration: time.Since(start),
+	}
+	res.ExitCode = ExitCode(err)
+	if ps := r.processState; ps != nil {
+		res.ExitCode = ps.ExitCode()
+		res.UserTime = ps.UserTime()
+		res.SystemTime = ps.SystemTime()
+		res.MaxRSS = maxRSS(ps)
+	}
+	return res, err
+}
@@ -0,0 +1,99 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"regexp"
+	"time"
+)
+
+// Backoff - Returns the delay before retrying after the given failed attempt, starting at 1.
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff - Waits the same delay before every retry.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff - Doubles the delay before every retry, starting at initial and capped at maxDelay.
+func ExponentialBackoff(initial, maxDelay time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := initial
+		for i := 1; i < attempt && d < maxDelay; i++ {
+			d *= 2
+		}
+		if d > maxDelay {
+			return maxDelay
+		}
+		return d
+	}
+}
+
+// RetryIf - Decides if a failed attempt is retried based on its exit code and stderr output.
+// The exit code is -1 if the command didn't exit normally, e.g. it wasn't found.
+type RetryIf func(exitCode int, stderr []byte) bool
+
+// RetryOnExitCodes - Retries when the command exits with any of the given exit codes.
+func RetryOnExitCodes(codes ...int) RetryIf {
+	return func(exitCode int, stderr []byte) bool {
+		for _, code := range codes {
+			if exitCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryOnStderr - Retries when the stderr output matches the given regex.
+func RetryOnStderr(re *regexp.Regexp) RetryIf {
+	return func(exitCode int, stderr []byte) bool {
+		return re.Match(stderr)
+	}
+}
+
+type retryInfo struct {
+	maxAttempts int
+	backoff     Backoff
+	retryIf     RetryIf
+}
+
+// Retry - Run the command up to maxAttempts times while it fails and retryIf returns true.
+// A nil backoff retries right away and a nil retryIf retries on any failure.
+//
+//	out, err := run.CMD("kubectl", "get", "pods").
+//		Retry(3, run.ExponentialBackoff(time.Second, 10*time.Second), run.RetryOnStderr(regexp.MustCompile(`connection refused`))).
+//		STDOutOutput()
+//
+// Every attempt gets the same stdin and the output returned by CombinedOutput and STDOutOutput is the output of the last attempt.
+// Output written to the Run writers includes the output of every attempt.
+// Retries stop when the context is done.
+func (r *RunInfo) Retry(maxAttempts int, backoff Backoff, retryIf RetryIf) *RunInfo {
+	r.retry = &retryInfo{maxAttempts: maxAttempts, backoff: backoff, retryIf: retryIf}
+	return r
+}
+
+func (ri *retryInfo) retryable(attempt int, err error, stderr []byte) bool {
+	if ri == nil || err == nil || attempt >= ri.maxAttempts {
+		return false
+	}
+	if ri.retryIf == nil {
+		return true
+	}
+	return ri.retryIf(ExitCode(err), stderr)
+}
+
+func (ri *retryInfo) delay(attempt int) time.Duration {
+	if ri.backoff == nil {
+		return 0
+	}
+	return ri.backoff(attempt)
+}
@@ -14,11 +14,15 @@ package run
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"regexp"
+	"sync"
+	"time"
 )
 
 var Logger = log.New(os.Stderr, "", log.LstdFlags)
@@ -39,6 +43,26 @@ type RunInfo struct {
 	ctx      context.Context
 	mockFn   MockFn
 	dryRun   bool
+
+	prefix          string
+	timestampLayout string
+	stdoutLineFn    LineFn
+	stderrLineFn    LineFn
+
+	processGroup bool
+	termSignal   os.Signal
+	gracePeriod  time.Duration
+	timeout      time.Duration
+
+	retry     *retryInfo
+	output    *bytes.Buffer // output buffer of CombinedOutput and STDOutOutput, reset between retries
+	errOutput *bytes.Buffer // stderr buffer of Exec, reset between retries
+
+	processState *os.ProcessState // state of the last completed run
+
+	maskValues   []string
+	maskPatterns []*regexp.Regexp
+	maskOutput   bool
 }
 
 type runInfoContextKey string
@@ -60,11 +84,17 @@ func CMD(cmd ...string) *RunInfo {
 
 // CMD - Pulls RunInfo from context if it exists and if not it initializes a new one.
 // Useful when loading a RunInfo from context to ease testing.
+//
+// The RunInfo from context is copied so that every call returns an independent command.
+// Changes made to the returned RunInfo, like Env or Log, don't modify the RunInfo stored in the context
+// and the stored RunInfo Cmd is no longer set to the last command.
 func CMDCtx(ctx context.Context, cmd ...string) *RunInfo {
 	v, ok := ctx.Value(runInfoContextKey("runInfo")).(*RunInfo)
 	if ok {
-		v.Cmd = cmd
-		return v
+		c := *v
+		c.Cmd = cmd
+		c.env = append([]string{}, v.env...)
+		return &c
 	}
 	r := CMD(cmd...)
 	r.ctx = ctx
@@ -111,6 +141,11 @@ func (r *RunInfo) Dir(dir string) *RunInfo {
 	return r
 }
 
+// GetStdin - used for testing, returns the reader connected to the command stdin or nil.
+func (r *RunInfo) GetStdin() io.Reader {
+	return r.stdin
+}
+
 // GetDir - used for testing
 func (r *RunInfo) GetDir() string {
 	return r.dir
@@ -122,6 +157,35 @@ func (r *RunInfo) Ctx(ctx context.Context) *RunInfo {
 	return r
 }
 
+// Timeout - Terminate the command if it doesn't complete within the given duration.
+//
+// The returned error is a *TerminatedError that matches ErrTimeout and context.DeadlineExceeded with errors.Is.
+func (r *RunInfo) Timeout(d time.Duration) *RunInfo {
+	r.timeout = d
+	return r
+}
+
+// ProcessGroup - Run the command in a new process group so that termination signals reach its child processes as well.
+// For example, Terraform and its provider plugins.
+//
+// Only supported on Unix like systems, ignored otherwise.
+func (r *RunInfo) ProcessGroup() *RunInfo {
+	r.processGroup = true
+	return r
+}
+
+// Terminate - When the context is done or the timeout expires, send the given signal to the command first, e.g. os.Interrupt or syscall.SIGTERM.
+// If the command hasn't exited after the grace period, it is killed.
+//
+// By default the command is killed right away.
+//
+//	err := run.CMD("terraform", "apply").Ctx(ctx).ProcessGroup().Terminate(os.Interrupt, 30*time.Second).Run()
+func (r *RunInfo) Terminate(sig os.Signal, gracePeriod time.Duration) *RunInfo {
+	r.termSignal = sig
+	r.gracePeriod = gracePeriod
+	return r
+}
+
 // SaveErr - If the command starts but does not complete successfully, the error is of
 // type *ExitError. In this case, save the error output into *ExitError.Stderr for retrieval.
 //
@@ -132,6 +196,8 @@ func (r *RunInfo) Ctx(ctx context.Context) *RunInfo {
 //	  var exitErr *exec.ExitError
 //	  if errors.As(err, &exitErr) {
 //	    errOutput := exitErr.Stderr
+//
+// Mocked commands return a *run.ExitError, its Stderr is saved as well.
 func (r *RunInfo) SaveErr() *RunInfo {
 	r.saveErr = true
 	return r
@@ -151,11 +217,47 @@ func (r *RunInfo) DiscardErr() *RunInfo {
 	return r
 }
 
+// Prefix - Prefix every line of the command stdout and stderr output with the given prefix.
+//
+//	err := run.CMD("terraform", "plan").Prefix("[vpc:dev] ").Run()
+//
+// Output is written line by line, a trailing line without a newline gets one added.
+// Output returned by CombinedOutput and STDOutOutput is prefixed as well.
+func (r *RunInfo) Prefix(prefix string) *RunInfo {
+	r.prefix = prefix
+	return r
+}
+
+// Timestamps - Start every line of the command stdout and stderr output with the current time in the given layout.
+//
+//	err := run.CMD("terraform", "plan").Timestamps(time.RFC3339).Prefix("[vpc:dev] ").Run()
+//	// 2024-01-02T15:04:05Z [vpc:dev] ...
+func (r *RunInfo) Timestamps(layout string) *RunInfo {
+	r.timestampLayout = layout
+	return r
+}
+
+// OnLine - Deliver the command stdout and stderr output line by line to the given callbacks.
+// Either callback can be nil.
+//
+// The callbacks receive the lines without the trailing newline and without the Prefix or Timestamps.
+// Output is still written to the command Stdout and Stderr writers.
+//
+//	err := run.CMD("terraform", "plan").OnLine(nil, func(line string) {
+//		if strings.Contains(line, "Error:") { ... }
+//	}).Run()
+func (r *RunInfo) OnLine(stdout, stderr LineFn) *RunInfo {
+	r.stdoutLineFn = stdout
+	r.stderrLineFn = stderr
+	return r
+}
+
 // CombinedOutput - Runs given CMD and returns STDOut and STDErr combined.
 func (r *RunInfo) CombinedOutput() ([]byte, error) {
 	var b bytes.Buffer
 	r.Stdout = &b
 	r.Stderr = &b
+	r.output = &b
 	err := r.Run()
 	return b.Bytes(), err
 }
@@ -166,6 +268,7 @@ func (r *RunInfo) CombinedOutput() ([]byte, error) {
 func (r *RunInfo) STDOutOutput() ([]byte, error) {
 	var b bytes.Buffer
 	r.Stdout = &b
+	r.output = &b
 	err := r.Run()
 	return b.Bytes(), err
 }
@@ -198,7 +301,7 @@ func (r *RunInfo) Run(w ...io.Writer) error {
 		if r.dryRun {
 			msg += "DRY-RUN "
 		}
-		msg += fmt.Sprintf("run %v", r.Cmd)
+		msg += fmt.Sprintf("run %v", r.maskedCmd())
 		if r.dir != "" {
 			msg += fmt.Sprintf(" on %s", r.dir)
 		}
@@ -215,15 +318,75 @@ func (r *RunInfo) Run(w ...io.Writer) error {
 		r.Stdout = w[0]
 		r.Stderr = w[1]
 	}
+	// Decide on printing stderr with the given writers, before they are wrapped
+	teeErr := false
 	if r.printErr {
 		if r.Stderr == nil {
 			r.Stderr = osStderr
 		} else if r.Stderr != osStderr {
-			r.Stderr = io.MultiWriter(r.Stderr, osStderr)
+			teeErr = true
+		}
+	}
+	if r.Stdout != nil && sameWriter(r.Stdout, r.Stderr) {
+		// Stderr can be wrapped, at which point the command output is copied to the shared writer concurrently
+		sw := &syncWriter{w: r.Stdout}
+		r.Stdout = sw
+		r.Stderr = sw
+	}
+	if teeErr {
+		r.Stderr = io.MultiWriter(r.Stderr, osStderr)
+	}
+	lineWriters := r.wrapLines()
+	flush := func() {
+		for _, lw := range lineWriters {
+			lw.Flush()
+		}
+	}
+
+	r.processState = nil
+	var stdin []byte
+	if r.retry != nil && r.stdin != nil && r.stdin != io.Reader(os.Stdin) {
+		// Every attempt gets the same input
+		var err error
+		stdin, err = io.ReadAll(r.stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		if stdin != nil {
+			r.stdin = bytes.NewReader(stdin)
+		}
+		stderr, err := r.runAttempt()
+		flush()
+		if r.ctx.Err() != nil || !r.retry.retryable(attempt, err, stderr) {
+			return err
+		}
+		delay := r.retry.delay(attempt)
+		Logger.Printf("retrying %v in %s, attempt %d/%d failed: %s\n", r.maskedCmd(), delay, attempt, r.retry.maxAttempts, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if r.output != nil {
+			r.output.Reset()
+		}
+		if r.errOutput != nil {
+			r.errOutput.Reset()
 		}
 	}
+}
+
+// runAttempt - Runs the command, or the mock function, once.
+// Returns the stderr output when it needs to be saved or checked for retries.
+func (r *RunInfo) runAttempt() ([]byte, error) {
+	stderr := r.Stderr
+	defer func() { r.Stderr = stderr }()
 	var b bytes.Buffer
-	if r.saveErr {
+	if r.saveErr || r.retry != nil {
 		if r.Stderr == nil {
 			r.Stderr = &b
 		} else {
@@ -231,31 +394,164 @@ func (r *RunInfo) Run(w ...io.Writer) error {
 		}
 	}
 
+	var err error
 	if r.mockFn != nil {
-		err := r.mockFn(r)
-		if err != nil && r.saveErr {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitErr.Stderr = b.Bytes()
-			}
+		err = r.mockFn(r)
+	} else if r.dryRun {
+		return nil, nil
+	} else {
+		err = r.runCmd(r.Stdout, r.Stderr, r.stdin)
+	}
+	if err != nil && r.saveErr {
+		saved := b.Bytes()
+		if r.maskOutput {
+			saved = []byte(r.mask(b.String()))
+		}
+		var exitErr *exec.ExitError
+		var mockErr *ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = saved
+		} else if errors.As(err, &mockErr) {
+			mockErr.Stderr = saved
 		}
-		return err
 	}
-	if r.dryRun {
-		return nil
+	return b.Bytes(), r.maskErr(err)
+}
+
+// runCmd - Runs the command with the given stdio.
+// When the context is done or the timeout expires, the command is terminated and a *TerminatedError is returned.
+func (r *RunInfo) runCmd(stdout, stderr io.Writer, stdin io.Reader) error {
+	ctx := r.ctx
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	if err := r.ctx.Err(); err != nil {
+		return err
 	}
 
-	c := exec.CommandContext(r.ctx, r.Cmd[0], r.Cmd[1:]...)
+	c := exec.Command(r.Cmd[0], r.Cmd[1:]...)
 	c.Dir = r.dir
 	c.Env = r.env
-	c.Stdout = r.Stdout
-	c.Stderr = r.Stderr
-	c.Stdin = r.stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	c.Stdin = stdin
+	if r.processGroup {
+		setProcessGroup(c)
+	}
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := c.Wait()
+		r.processState = c.ProcessState
+		done <- err
+	}()
 
-	err := c.Run()
-	if err != nil && r.saveErr {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitErr.Stderr = b.Bytes()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	tErr := &TerminatedError{Cmd: r.maskedCmd(), Reason: r.ctx.Err(), Signal: os.Kill}
+	if tErr.Reason == nil {
+		tErr.Reason = ErrTimeout
+	}
+	if r.termSignal != nil {
+		tErr.Signal = r.termSignal
+	}
+	if r.debug {
+		Logger.Printf("terminating %v with %s: %s\n", tErr.Cmd, tErr.Signal, tErr.Reason)
+	}
+	err = signalProcess(c, tErr.Signal, r.processGroup)
+	if errors.Is(err, os.ErrProcessDone) {
+		// Completed on its own
+		return <-done
+	}
+	if err != nil && tErr.Signal != os.Kill {
+		// The signal is not supported, e.g. os.Interrupt on Windows
+		tErr.Signal = os.Kill
+		_ = signalProcess(c, os.Kill, r.processGroup)
+	}
+	if tErr.Signal != os.Kill {
+		timer := time.NewTimer(r.gracePeriod)
+		defer timer.Stop()
+		select {
+		case tErr.Err = <-done:
+			return tErr
+		case <-timer.C:
+			if r.debug {
+				Logger.Printf("killing %v after grace period %s\n", tErr.Cmd, r.gracePeriod)
+			}
+			tErr.Killed = true
+			_ = signalProcess(c, os.Kill, r.processGroup)
 		}
 	}
-	return err
+	tErr.Err = <-done
+	return tErr
+}
+
+// wrapLines - Wraps Stdout and Stderr to apply the Prefix, Timestamps, OnLine and MaskOutput options.
+// Returns the line writers that need to be flushed after the command completes.
+func (r *RunInfo) wrapLines() []*LineWriter {
+	if r.prefix == "" && r.timestampLayout == "" && r.stdoutLineFn == nil && r.stderrLineFn == nil && !r.maskOutput {
+		return nil
+	}
+	// Stdout and Stderr can point to the same writer, serialize the writes
+	mu := &sync.Mutex{}
+	lineWriters := []*LineWriter{}
+	wrap := func(w io.Writer, fn LineFn) io.Writer {
+		if w == nil && fn == nil {
+			return nil
+		}
+		lw := &LineWriter{mu: mu, fn: func(line string) {
+			if r.maskOutput {
+				line = r.mask(line)
+			}
+			if fn != nil {
+				fn(line)
+			}
+			if w != nil {
+				fmt.Fprintf(w, "%s%s\n", r.linePrefix(), line)
+			}
+		}}
+		lineWriters = append(lineWriters, lw)
+		return lw
+	}
+	r.Stdout = wrap(r.Stdout, r.stdoutLineFn)
+	r.Stderr = wrap(r.Stderr, r.stderrLineFn)
+	return lineWriters
+}
+
+func (r *RunInfo) linePrefix() string {
+	if r.timestampLayout == "" {
+		return r.prefix
+	}
+	return time.Now().Format(r.timestampLayout) + " " + r.prefix
+}
+
+// sameWriter - Reports whether a and b are the same writer.
+// Writers with a type that can't be compared are never the same.
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
 }
@@ -1,8 +1,10 @@
 package run_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"slices"
 	"testing"
 
@@ -81,5 +83,80 @@ func TestRunWithMocks(t *testing.T) {
 		if string(out) != "not found x\n" {
 			t.Errorf("wrong output: %s\n", out)
 		}
+
+		// Every call returns a copy, the stored RunInfo is not modified
+		r1 := run.CMDCtx(ctx, "ls", "./run").Env("A=1")
+		r2 := run.CMDCtx(ctx, "ls", "x")
+		if r1 == r2 || r1 == mockR {
+			t.Errorf("expected independent RunInfo copies")
+		}
+		if mockR.Cmd != nil || slices.Compare(r1.Cmd, []string{"ls", "./run"}) != 0 {
+			t.Errorf("wrong commands: %v, %v", mockR.Cmd, r1.Cmd)
+		}
+	})
+}
+
+func TestRunLinesWithMocks(t *testing.T) {
+	lines := []string{}
+	r := run.CMD("ls", "./run").Prefix("[ls] ").OnLine(func(line string) { lines = append(lines, line) }, nil)
+	r.Mock(func(r *run.RunInfo) error {
+		r.Stdout.Write([]byte("hello world\nhola"))
+		return nil
+	})
+	out, err := r.STDOutOutput()
+	if err != nil {
+		t.Errorf("unexpected error")
+	}
+	if string(out) != "[ls] hello world\n[ls] hola\n" {
+		t.Errorf("wrong output: %q\n", out)
+	}
+	if !slices.Equal(lines, []string{"hello world", "hola"}) {
+		t.Errorf("wrong lines: %v\n", lines)
+	}
+}
+
+func TestPipeWithMocks(t *testing.T) {
+	upper := run.CMD("tr", "a-z", "A-Z").Mock(func(r *run.RunInfo) error {
+		in, err := io.ReadAll(r.GetStdin())
+		if err != nil {
+			return err
+		}
+		r.Stdout.Write(bytes.ToUpper(in))
+		return nil
+	})
+	out, err := run.Pipe(run.CMD("echo", "hello"), upper).STDOutOutput()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if string(out) != "HELLO\n" {
+		t.Errorf("wrong output: %q\n", out)
+	}
+
+	t.Run("mock with context", func(t *testing.T) {
+		ctx := context.Background()
+		mockR := run.CMD().Mock(func(r *run.RunInfo) error {
+			switch r.Cmd[0] {
+			case "kubectl":
+				r.Stdout.Write([]byte("pod/web-1\npod/db-1\n"))
+				return nil
+			case "grep":
+				in, _ := io.ReadAll(r.GetStdin())
+				if string(in) != "pod/web-1\npod/db-1\n" {
+					return fmt.Errorf("unexpected input: %q", in)
+				}
+				r.Stdout.Write([]byte("pod/web-1\n"))
+				return nil
+			default:
+				return fmt.Errorf("unexpected command: %s", r.Cmd)
+			}
+		})
+		ctx = run.ContextWithRunInfo(ctx, mockR)
+		out, err := run.Pipe(run.CMDCtx(ctx, "kubectl", "get", "pods"), run.CMDCtx(ctx, "grep", "web")).STDOutOutput()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if string(out) != "pod/web-1\n" {
+			t.Errorf("wrong output: %q\n", out)
+		}
 	})
 }
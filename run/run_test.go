@@ -15,8 +15,14 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -83,6 +89,7 @@ func TestRun(t *testing.T) {
 	t.Run("STDOutOutput print stderr", func(t *testing.T) {
 		var b bytes.Buffer
 		osStderr = &b
+		defer func() { osStderr = os.Stderr }()
 		out, err := CMD("ls", "x").PrintErr().STDOutOutput()
 		if err == nil {
 			t.Errorf("Unexpected pass: %s\n", err)
@@ -105,6 +112,19 @@ func TestRun(t *testing.T) {
 		}
 	})
 
+	t.Run("Run to stderr", func(t *testing.T) {
+		var b bytes.Buffer
+		osStderr = &b
+		defer func() { osStderr = os.Stderr }()
+		err := CMD("sh", "-c", "echo to-stdout; echo to-stderr >&2").Run(osStderr)
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if b.String() != "to-stdout\nto-stderr\n" {
+			t.Errorf("wrong osStderr output: %q\n", b.String())
+		}
+	})
+
 	out, err = CMD("echo", "-n", "hello", "world").CombinedOutput()
 	if err != nil {
 		t.Errorf("Unexpected error: %s\n", err)
@@ -136,3 +156,526 @@ func TestRun(t *testing.T) {
 		t.Errorf("Unexpected pass: %s\n", err)
 	}
 }
+
+func TestRunLines(t *testing.T) {
+	t.Run("Prefix", func(t *testing.T) {
+		out, err := CMD("printf", "hello\nworld").Prefix("[vpc:dev] ").CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(out) != "[vpc:dev] hello\n[vpc:dev] world\n" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+	})
+
+	t.Run("Timestamps", func(t *testing.T) {
+		out, err := CMD("echo", "hello").Timestamps("2006").Prefix("[x] ").CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		expected := time.Now().Format("2006") + " [x] hello\n"
+		if string(out) != expected {
+			t.Errorf("wrong output: %q, want %q\n", out, expected)
+		}
+	})
+
+	t.Run("OnLine", func(t *testing.T) {
+		stdout := []string{}
+		stderr := []string{}
+		var b bytes.Buffer
+		err := CMD("sh", "-c", "echo out1; echo err1 >&2; echo out2").
+			OnLine(func(line string) { stdout = append(stdout, line) }, func(line string) { stderr = append(stderr, line) }).
+			DiscardErr().Run(&b, nil)
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if strings.Join(stdout, ",") != "out1,out2" {
+			t.Errorf("wrong stdout lines: %v\n", stdout)
+		}
+		if strings.Join(stderr, ",") != "err1" {
+			t.Errorf("wrong stderr lines: %v\n", stderr)
+		}
+		if b.String() != "out1\nout2\n" {
+			t.Errorf("wrong output: %q\n", b.String())
+		}
+	})
+
+	t.Run("SaveErr is not prefixed", func(t *testing.T) {
+		var b bytes.Buffer
+		err := CMD("sh", "-c", "echo failed >&2; exit 3").Prefix("[x] ").SaveErr().Run(&b)
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("Unexpected error: %s\n", err)
+		}
+		if string(exitErr.Stderr) != "failed\n" {
+			t.Errorf("wrong stderr output: %q\n", exitErr.Stderr)
+		}
+		if b.String() != "[x] failed\n" {
+			t.Errorf("wrong output: %q\n", b.String())
+		}
+	})
+}
+
+func TestPipe(t *testing.T) {
+	out, err := Pipe(CMD("echo", "hello world"), CMD("tr", "a-z", "A-Z"), CMD("rev")).CombinedOutput()
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	if string(out) != "DLROW OLLEH\n" {
+		t.Errorf("wrong output: %q\n", out)
+	}
+
+	t.Run("early exit is not a failure", func(t *testing.T) {
+		out, err := Pipe(CMD("yes"), CMD("head", "-n", "1")).STDOutOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(out) != "y\n" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+	})
+
+	t.Run("failed stage", func(t *testing.T) {
+		for _, tt := range []struct {
+			cmds  []*RunInfo
+			stage int
+			code  int
+		}{
+			{[]*RunInfo{CMD("sh", "-c", "exit 3"), CMD("cat")}, 0, 3},
+			{[]*RunInfo{CMD("echo", "hello"), CMD("sh", "-c", "exit 2")}, 1, 2},
+		} {
+			_, err := Pipe(tt.cmds...).DiscardErr().CombinedOutput()
+			var pipeErr *PipeError
+			if !errors.As(err, &pipeErr) {
+				t.Fatalf("wrong error: %v\n", err)
+			}
+			if pipeErr.Stage != tt.stage || pipeErr.ExitCode() != tt.code {
+				t.Errorf("wrong stage or exit code: %d %d\n", pipeErr.Stage, pipeErr.ExitCode())
+			}
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				t.Errorf("expected exec.ExitError: %v\n", err)
+			}
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := Pipe(CMD("sleep", "5"), CMD("sleep", "5")).Ctx(ctx).CombinedOutput()
+		if err == nil {
+			t.Errorf("Unexpected pass\n")
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("pipeline not cancelled: %s\n", time.Since(start))
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		var b bytes.Buffer
+		Logger.SetOutput(&b)
+		defer Logger.SetOutput(os.Stderr)
+		out, err := Pipe(CMD("echo", "hello"), CMD("cat")).Log().DryRun(true).CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(out) != "" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+		if !strings.Contains(b.String(), "DRY-RUN run [echo hello] | [cat]") {
+			t.Errorf("wrong log output: %q\n", b.String())
+		}
+	})
+}
+
+type fakeTB struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper()           {}
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+func (f *fakeTB) done() {
+	for _, fn := range f.cleanups {
+		fn()
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "testdata", "recordings.json")
+
+	runCmds := func(mock MockFn) {
+		out, err := CMD("echo", "hello").Env("GREETING=hola").Mock(mock).STDOutOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(out) != "hello\n" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+		out, err = CMD("cat").In([]byte("from stdin")).Mock(mock).CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(out) != "from stdin" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+		_, err = CMD("sh", "-c", "echo failed >&2; exit 3").Mock(mock).SaveErr().DiscardErr().STDOutOutput()
+		// The real command returns an *exec.ExitError and the replayed one an *ExitError
+		var stderr []byte
+		var exitErr *exec.ExitError
+		var mockErr *ExitError
+		if errors.As(err, &exitErr) {
+			stderr = exitErr.Stderr
+		} else if errors.As(err, &mockErr) {
+			stderr = mockErr.Stderr
+		} else {
+			t.Fatalf("wrong error: %v\n", err)
+		}
+		if ExitCode(err) != 3 || string(stderr) != "failed\n" {
+			t.Errorf("wrong exit error: %d %q\n", ExitCode(err), stderr)
+		}
+		// Dry runs are neither run, recorded nor replayed
+		err = CMD("false").Mock(mock).DryRun(true).Run()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		t.Setenv(RecordEnvVar, "true")
+		tb := &fakeTB{}
+		runCmds(RecordReplay(tb, file))
+		tb.done()
+		if len(tb.errors) > 0 {
+			t.Errorf("unexpected errors: %v\n", tb.errors)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s\n", err)
+		}
+		for _, s := range []string{`"GREETING=hola"`, `"stdin": "from stdin"`, `"exit_code": 3`} {
+			if !strings.Contains(string(data), s) {
+				t.Errorf("recording missing %s: %s\n", s, data)
+			}
+		}
+		if strings.Contains(string(data), `"false"`) {
+			t.Errorf("dry run recorded: %s\n", data)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		t.Setenv(RecordEnvVar, "")
+		// Recorded output, not the real one
+		data, _ := os.ReadFile(file)
+		os.WriteFile(file, bytes.Replace(data, []byte(`"stdout": "hello\n"`), []byte(`"stdout": "replayed\n"`), 1), 0644)
+
+		tb := &fakeTB{}
+		mock := RecordReplay(tb, file)
+		out, err := CMD("echo", "hello").Env("GREETING=hola").Mock(mock).STDOutOutput()
+		if err != nil || string(out) != "replayed\n" {
+			t.Errorf("wrong output: %q, %v\n", out, err)
+		}
+		_, err = CMD("echo", "other").Mock(mock).STDOutOutput()
+		if !errors.Is(err, ErrUnexpectedCommand) {
+			t.Errorf("wrong error: %v\n", err)
+		}
+		// Same args and stdin with a different env or dir
+		_, err = CMD("cat").In([]byte("from stdin")).Env("GREETING=hello").Mock(mock).STDOutOutput()
+		if !errors.Is(err, ErrUnexpectedCommand) {
+			t.Errorf("wrong error: %v\n", err)
+		}
+		_, err = CMD("cat").In([]byte("from stdin")).Dir("other").Mock(mock).STDOutOutput()
+		if !errors.Is(err, ErrUnexpectedCommand) {
+			t.Errorf("wrong error: %v\n", err)
+		}
+		tb.done()
+		// unexpected commands and the two recordings not run
+		if len(tb.errors) != 5 {
+			t.Errorf("wrong errors: %v\n", tb.errors)
+		}
+	})
+
+	t.Run("replay all", func(t *testing.T) {
+		t.Setenv(RecordEnvVar, "")
+		tb := &fakeTB{}
+		data, _ := os.ReadFile(file)
+		os.WriteFile(file, bytes.Replace(data, []byte(`"stdout": "replayed\n"`), []byte(`"stdout": "hello\n"`), 1), 0644)
+		runCmds(RecordReplay(tb, file))
+		tb.done()
+		if len(tb.errors) > 0 {
+			t.Errorf("unexpected errors: %v\n", tb.errors)
+		}
+	})
+}
+
+func TestRunTerminate(t *testing.T) {
+	t.Run("Timeout", func(t *testing.T) {
+		start := time.Now()
+		err := CMD("sleep", "5").Timeout(50 * time.Millisecond).Run()
+		var tErr *TerminatedError
+		if !errors.As(err, &tErr) {
+			t.Fatalf("wrong error: %v\n", err)
+		}
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wrong reason: %v\n", err)
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("expected exec.ExitError: %v\n", err)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("command not terminated: %s\n", time.Since(start))
+		}
+	})
+
+	t.Run("graceful", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		out, err := CMD("sh", "-c", `trap "echo terminated; exit 0" TERM; sleep 5 & wait`).
+			Ctx(ctx).ProcessGroup().Terminate(syscall.SIGTERM, 5*time.Second).CombinedOutput()
+		var tErr *TerminatedError
+		if !errors.As(err, &tErr) {
+			t.Fatalf("wrong error: %v\n", err)
+		}
+		if !errors.Is(err, context.Canceled) || tErr.Killed || tErr.Signal != syscall.SIGTERM {
+			t.Errorf("wrong termination: %v\n", err)
+		}
+		if string(out) != "terminated\n" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("command not terminated: %s\n", time.Since(start))
+		}
+	})
+
+	t.Run("killed after grace period", func(t *testing.T) {
+		start := time.Now()
+		err := CMD("sh", "-c", `trap "" TERM; sleep 5`).
+			Timeout(100*time.Millisecond).ProcessGroup().Terminate(syscall.SIGTERM, 100*time.Millisecond).Run()
+		var tErr *TerminatedError
+		if !errors.As(err, &tErr) {
+			t.Fatalf("wrong error: %v\n", err)
+		}
+		if !tErr.Killed {
+			t.Errorf("expected kill: %v\n", err)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("command not killed: %s\n", time.Since(start))
+		}
+	})
+}
+
+func TestRunRetry(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "counter")
+	flaky := `n=$(cat ` + counter + ` 2>/dev/null || echo 0); n=$((n+1)); echo $n > ` + counter + `
+if [ $n -lt 3 ]; then echo "attempt $n failed" >&2; exit 7; fi
+echo "attempt $n"`
+
+	t.Run("retry until success", func(t *testing.T) {
+		var logs bytes.Buffer
+		Logger.SetOutput(&logs)
+		defer Logger.SetOutput(os.Stderr)
+		out, err := CMD("sh", "-c", flaky).DiscardErr().Retry(3, ConstantBackoff(time.Millisecond), RetryOnExitCodes(7)).CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(out) != "attempt 3\n" {
+			t.Errorf("wrong output: %q\n", out)
+		}
+		if strings.Count(logs.String(), "retrying") != 2 {
+			t.Errorf("wrong logs: %s\n", logs.String())
+		}
+	})
+
+	t.Run("predicate", func(t *testing.T) {
+		os.Remove(counter)
+		_, err := CMD("sh", "-c", flaky).DiscardErr().Retry(3, nil, RetryOnStderr(regexp.MustCompile(`timeout`))).CombinedOutput()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 7 {
+			t.Errorf("wrong error: %v\n", err)
+		}
+		data, _ := os.ReadFile(counter)
+		if string(data) != "1\n" {
+			t.Errorf("wrong number of attempts: %s\n", data)
+		}
+	})
+
+	t.Run("max attempts with mock", func(t *testing.T) {
+		inputs := []string{}
+		_, err := CMD("cat").In([]byte("input")).Retry(3, nil, nil).SaveErr().Mock(func(r *RunInfo) error {
+			in, _ := io.ReadAll(r.GetStdin())
+			inputs = append(inputs, string(in))
+			r.Stderr.Write([]byte(fmt.Sprintf("failure %d\n", len(inputs))))
+			return &ExitError{Code: 1}
+		}).DiscardErr().STDOutOutput()
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || string(exitErr.Stderr) != "failure 3\n" {
+			t.Errorf("wrong error: %v\n", err)
+		}
+		if strings.Join(inputs, ",") != "input,input,input" {
+			t.Errorf("wrong inputs: %v\n", inputs)
+		}
+	})
+
+	t.Run("Exec output of the last attempt", func(t *testing.T) {
+		attempts := 0
+		res, err := CMD("ls").Retry(3, ConstantBackoff(time.Millisecond), nil).Mock(func(r *RunInfo) error {
+			attempts++
+			r.Stdout.Write([]byte(fmt.Sprintf("out %d\n", attempts)))
+			r.Stderr.Write([]byte(fmt.Sprintf("err %d\n", attempts)))
+			if attempts == 1 {
+				return &ExitError{Code: 1}
+			}
+			return nil
+		}).Exec()
+		if err != nil {
+			t.Errorf("Unexpected error: %s\n", err)
+		}
+		if string(res.Stdout) != "out 2\n" || string(res.Stderr) != "err 2\n" {
+			t.Errorf("wrong output: %q %q\n", res.Stdout, res.Stderr)
+		}
+	})
+
+	t.Run("ExponentialBackoff", func(t *testing.T) {
+		backoff := ExponentialBackoff(time.Second, 5*time.Second)
+		got := []time.Duration{}
+		for i := 1; i <= 4; i++ {
+			got = append(got, backoff(i))
+		}
+		expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("got %v, want %v\n", got, expected)
+		}
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	_, realErr := CMD("sh", "-c", "exit 4").DiscardErr().STDOutOutput()
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, 0},
+		{"exec", realErr, 4},
+		{"mock", &ExitError{Code: 5}, 5},
+		{"wrapped", fmt.Errorf("failed: %w", &ExitError{Code: 6}), 6},
+		{"other", errors.New("not found"), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.code {
+				t.Errorf("got %d, want %d\n", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestExec(t *testing.T) {
+	res, err := CMD("sh", "-c", "echo out; echo err >&2; exit 3").DiscardErr().Exec()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("wrong error: %v\n", err)
+	}
+	if string(res.Stdout) != "out\n" || string(res.Stderr) != "err\n" {
+		t.Errorf("wrong output: %q %q\n", res.Stdout, res.Stderr)
+	}
+	if res.ExitCode != 3 {
+		t.Errorf("wrong exit code: %d\n", res.ExitCode)
+	}
+	if res.Duration <= 0 || res.MaxRSS <= 0 {
+		t.Errorf("missing usage: %+v\n", res)
+	}
+
+	res, err = CMD("not-a-command").Exec()
+	if err == nil || res.ExitCode != -1 {
+		t.Errorf("wrong result: %v %+v\n", err, res)
+	}
+
+	res, err = CMD("ls").Mock(func(r *RunInfo) error {
+		r.Stdout.Write([]byte("hello\n"))
+		return nil
+	}).Exec()
+	if err != nil || string(res.Stdout) != "hello\n" || res.ExitCode != 0 || res.MaxRSS != 0 {
+		t.Errorf("wrong result: %v %+v\n", err, res)
+	}
+}
+
+func TestMask(t *testing.T) {
+	var logs bytes.Buffer
+	Logger.SetOutput(&logs)
+	defer Logger.SetOutput(os.Stderr)
+
+	r := CMD("sh", "-c", "echo token=s3cr3t pass=hunter2; echo s3cr3t >&2").
+		Mask("s3cr3t").MaskPattern(regexp.MustCompile(`pass=(\S+)`)).MaskOutput().Log()
+	out, err := r.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if string(out) != "token=***** pass=*****\n*****\n" {
+		t.Errorf("wrong output: %q\n", out)
+	}
+	if strings.Contains(logs.String(), "s3cr3t") || strings.Contains(logs.String(), "hunter2") {
+		t.Errorf("secret in logs: %s\n", logs.String())
+	}
+
+	t.Run("command only", func(t *testing.T) {
+		out, err := CMD("echo", "--token=s3cr3t").Mask("s3cr3t").STDOutOutput()
+		if err != nil || string(out) != "--token=s3cr3t\n" {
+			t.Errorf("wrong output: %q %v\n", out, err)
+		}
+		res, _ := CMD("echo", "--token=s3cr3t").Mask("s3cr3t").Exec()
+		if fmt.Sprintf("%v", res.Cmd) != "[echo --token=*****]" {
+			t.Errorf("wrong cmd: %v\n", res.Cmd)
+		}
+	})
+
+	t.Run("saved stderr", func(t *testing.T) {
+		_, err := CMD("sh", "-c", "echo token=s3cr3t >&2; exit 2").Mask("s3cr3t").MaskOutput().SaveErr().DiscardErr().STDOutOutput()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+			t.Fatalf("wrong error: %v\n", err)
+		}
+		if string(exitErr.Stderr) != "token=*****\n" {
+			t.Errorf("wrong saved stderr: %q\n", exitErr.Stderr)
+		}
+	})
+
+	t.Run("error message", func(t *testing.T) {
+		err := CMD("./s3cr3t-not-a-command").Mask("s3cr3t").Run()
+		if err == nil || strings.Contains(err.Error(), "s3cr3t") {
+			t.Errorf("secret in error: %v\n", err)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("original error not wrapped: %v\n", err)
+		}
+	})
+
+	t.Run("patterns", func(t *testing.T) {
+		tests := []struct {
+			re   string
+			in   string
+			want string
+		}{
+			{`s3cr3t`, "a s3cr3t b s3cr3t", "a ***** b *****"},
+			{`key=(\w+)`, "key=abc key=def", "key=***** key=*****"},
+			{`(user):(\w+)`, "user:abc", "*****:*****"},
+			{`x(y)?`, "x", "x"},
+			{`nomatch`, "abc", "abc"},
+		}
+		for _, tt := range tests {
+			got := maskMatches(regexp.MustCompile(tt.re), tt.in)
+			if got != tt.want {
+				t.Errorf("%s: got %q, want %q\n", tt.re, got, tt.want)
+			}
+		}
+	})
+}
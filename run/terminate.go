@@ -0,0 +1,55 @@
+// This file is part of run.
+//
+// Copyright (C) 2020-2024  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrTimeout - The command didn't complete within the duration given to RunInfo.Timeout.
+var ErrTimeout = errors.New("command timeout")
+
+// TerminatedError - The command was terminated because its context was done or its timeout expired.
+//
+// Use errors.As to get the *exec.ExitError of the terminated command and
+// errors.Is to check the reason, e.g. errors.Is(err, context.Canceled).
+type TerminatedError struct {
+	Cmd    []string  // with the command masks applied
+	Reason error     // context.Canceled, context.DeadlineExceeded or ErrTimeout
+	Signal os.Signal // first signal sent to the command
+	Killed bool      // the command was killed after the grace period
+	Err    error     // error returned by the command after termination
+}
+
+func (e *TerminatedError) Error() string {
+	msg := fmt.Sprintf("%v terminated with %s: %s", e.Cmd, e.Signal, e.Reason)
+	if e.Killed {
+		msg += ", killed after the grace period"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TerminatedError) Unwrap() error {
+	return e.Err
+}
+
+// Is - Matches the termination reason.
+// A timeout also matches context.DeadlineExceeded.
+func (e *TerminatedError) Is(target error) bool {
+	if errors.Is(e.Reason, target) {
+		return true
+	}
+	return target == context.DeadlineExceeded && errors.Is(e.Reason, ErrTimeout)
+}